@@ -0,0 +1,145 @@
+package glide
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/GlideApis/sdk-go/pkg/utils"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig is the layout of YAML and JSON config files. Keys match the
+// names used in configuration errors, e.g. internal.authBaseUrl.
+type fileConfig struct {
+	ClientID                 string   `json:"clientId" yaml:"clientId"`
+	ClientSecret             string   `json:"clientSecret" yaml:"clientSecret"`
+	RedirectURI              string   `json:"redirectUri" yaml:"redirectUri"`
+	UseEnv                   bool     `json:"useEnv" yaml:"useEnv"`
+	UseDiscovery             bool     `json:"useDiscovery" yaml:"useDiscovery"`
+	DiscoveryRefreshInterval duration `json:"discoveryRefreshInterval" yaml:"discoveryRefreshInterval"`
+	CIBAMode                 string   `json:"cibaMode" yaml:"cibaMode"`
+	Internal                 struct {
+		AuthBaseURL string   `json:"authBaseUrl" yaml:"authBaseUrl"`
+		APIBaseURL  string   `json:"apiBaseUrl" yaml:"apiBaseUrl"`
+		LogLevel    logLevel `json:"logLevel" yaml:"logLevel"`
+	} `json:"internal" yaml:"internal"`
+	Retry *struct {
+		MaxAttempts          int      `json:"maxAttempts" yaml:"maxAttempts"`
+		InitialBackoff       duration `json:"initialBackoff" yaml:"initialBackoff"`
+		MaxBackoff           duration `json:"maxBackoff" yaml:"maxBackoff"`
+		Multiplier           float64  `json:"multiplier" yaml:"multiplier"`
+		Jitter               float64  `json:"jitter" yaml:"jitter"`
+		RetryableStatusCodes []int    `json:"retryableStatusCodes" yaml:"retryableStatusCodes"`
+	} `json:"retry" yaml:"retry"`
+}
+
+// duration decodes strings such as "200ms" or "5s".
+type duration time.Duration
+
+func (d *duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = duration(parsed)
+	return nil
+}
+
+// logLevel decodes "debug", "info", "warn" or "error".
+type logLevel types.LogLevel
+
+func (l *logLevel) UnmarshalText(text []byte) error {
+	switch strings.ToLower(string(text)) {
+	case "":
+		*l = logLevel(types.UNSET)
+	case "debug":
+		*l = logLevel(types.DEBUG)
+	case "info":
+		*l = logLevel(types.INFO)
+	case "warn", "warning":
+		*l = logLevel(types.WARN)
+	case "error":
+		*l = logLevel(types.ERROR)
+	default:
+		return fmt.Errorf("unknown log level %q", text)
+	}
+	return nil
+}
+
+// LoadConfigFile reads settings from a YAML (.yaml, .yml), JSON (.json) or
+// dotenv (.env) file. Dotenv files use the GLIDE_* env var names and are not
+// loaded into the process environment. Fields missing from the file are left
+// empty, so the result can be passed to NewGlideClient after setting explicit
+// values, or used through WithConfigFile.
+func LoadConfigFile(path string) (types.GlideSdkSettings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return types.GlideSdkSettings{}, utils.ConfigError(fmt.Sprintf("reading config file: %v", err))
+	}
+	var cfg fileConfig
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&cfg)
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&cfg)
+	case ".env":
+		var env map[string]string
+		env, err = godotenv.UnmarshalBytes(data)
+		cfg.ClientID = env["GLIDE_CLIENT_ID"]
+		cfg.ClientSecret = env["GLIDE_CLIENT_SECRET"]
+		cfg.RedirectURI = env["GLIDE_REDIRECT_URI"]
+		cfg.Internal.AuthBaseURL = env["GLIDE_AUTH_BASE_URL"]
+		cfg.Internal.APIBaseURL = env["GLIDE_API_BASE_URL"]
+	default:
+		return types.GlideSdkSettings{}, utils.ConfigError(fmt.Sprintf("unsupported config file type %q", ext))
+	}
+	if err != nil {
+		return types.GlideSdkSettings{}, utils.ConfigError(fmt.Sprintf("parsing config file %s: %v", filepath.Base(path), err))
+	}
+
+	settings := types.GlideSdkSettings{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURI:  cfg.RedirectURI,
+		UseEnv:       cfg.UseEnv,
+		UseDiscovery: cfg.UseDiscovery,
+		Internal: types.InternalSettings{
+			AuthBaseURL: cfg.Internal.AuthBaseURL,
+			APIBaseURL:  cfg.Internal.APIBaseURL,
+			LogLevel:    types.LogLevel(cfg.Internal.LogLevel),
+		},
+		DiscoveryRefreshInterval: time.Duration(cfg.DiscoveryRefreshInterval),
+		CIBAMode:                 types.CIBAMode(cfg.CIBAMode),
+	}
+	if r := cfg.Retry; r != nil {
+		settings.Retry = &types.RetryPolicy{
+			MaxAttempts:          r.MaxAttempts,
+			InitialBackoff:       time.Duration(r.InitialBackoff),
+			MaxBackoff:           time.Duration(r.MaxBackoff),
+			Multiplier:           r.Multiplier,
+			Jitter:               r.Jitter,
+			RetryableStatusCodes: r.RetryableStatusCodes,
+		}
+	}
+	return settings, nil
+}
+
+// WithConfigFile loads settings from a file (see LoadConfigFile). File values
+// take precedence over env vars and defaults but not over other options,
+// whatever their order. The file's useEnv is ignored; use WithoutEnv.
+func WithConfigFile(path string) Option {
+	return func(o *options) {
+		o.files = append(o.files, path)
+	}
+}
@@ -1,8 +1,11 @@
 package glide
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
 
 	"github.com/GlideApis/sdk-go/pkg/services"
@@ -18,9 +21,16 @@ type GlideClient struct {
 	SimSwap      *services.SimSwapClient
 	NumberVerify *services.NumberVerifyClient
 	KYCMatch     *services.KYCMatchClient
+
+	observers *utils.Observers
 }
 
 func ReportMetric(report types.MetricInfo) error {
+	return ReportMetricWithContext(context.Background(), report)
+}
+
+// ReportMetricWithContext validates and reports a metric, bound to ctx.
+func ReportMetricWithContext(ctx context.Context, report types.MetricInfo) error {
 	if os.Getenv("REPORT_METRIC_URL") == "" {
 		return fmt.Errorf("missing process env REPORT_METRIC_URL")
 	}
@@ -42,36 +52,153 @@ func ReportMetric(report types.MetricInfo) error {
 	if report.Timestamp.IsZero() {
 		return fmt.Errorf("missing Timestamp")
 	}
-	utils.ReportMetric(report)
+	utils.ReportMetricWithContext(ctx, report)
 	return nil
 }
 
+// NewGlideClient builds a client from settings, filling unset fields with
+// the defaults. GLIDE_* env vars and REPORT_METRIC_URL are only used when
+// settings.UseEnv is true; New reads them by default.
 func NewGlideClient(settings types.GlideSdkSettings) (*GlideClient, error) {
+	// Merge defaults with provided settings
+	return newClient(mergeSettings(defaultSettings(settings.UseEnv), settings), settings.UseEnv)
+}
 
-	defaults := types.GlideSdkSettings{
-		ClientID:     os.Getenv("GLIDE_CLIENT_ID"),
-		ClientSecret: os.Getenv("GLIDE_CLIENT_SECRET"),
-		RedirectURI:  os.Getenv("GLIDE_REDIRECT_URI"),
+// defaultSettings returns the built-in defaults, overridden by GLIDE_* env
+// vars when useEnv is set.
+func defaultSettings(useEnv bool) types.GlideSdkSettings {
+	env := func(key, defaultValue string) string {
+		if !useEnv {
+			return defaultValue
+		}
+		return getEnvOrDefault(key, defaultValue)
+	}
+	return types.GlideSdkSettings{
+		ClientID:     env("GLIDE_CLIENT_ID", ""),
+		ClientSecret: env("GLIDE_CLIENT_SECRET", ""),
+		RedirectURI:  env("GLIDE_REDIRECT_URI", ""),
+		UseEnv:       useEnv,
 		Internal: types.InternalSettings{
-			AuthBaseURL: getEnvOrDefault("GLIDE_AUTH_BASE_URL", "https://oidc.gateway-x.io"),
-			APIBaseURL:  getEnvOrDefault("GLIDE_API_BASE_URL", "https://api.gateway-x.io"),
+			AuthBaseURL: env("GLIDE_AUTH_BASE_URL", "https://oidc.gateway-x.io"),
+			APIBaseURL:  env("GLIDE_API_BASE_URL", "https://api.gateway-x.io"),
 			LogLevel:    types.ERROR,
 		},
+		Retry:     utils.DefaultRetryPolicy(),
+		Collector: utils.NewMemoryCollector(),
 	}
+}
 
-	// Merge defaults with provided settings
-	mergedSettings := mergeSettings(defaults, settings)
+// validateSettings checks settings and returns every problem found, joined.
+func validateSettings(settings types.GlideSdkSettings) error {
+	var errs []error
+	if settings.ClientID == "" && settings.Credentials == nil {
+		errs = append(errs, utils.ConfigError("clientId is required"))
+	}
+	if settings.Internal.AuthBaseURL == "" {
+		errs = append(errs, utils.ConfigError("internal.authBaseUrl is unset"))
+	}
+	for _, u := range []struct{ name, value string }{
+		{"internal.authBaseUrl", settings.Internal.AuthBaseURL},
+		{"internal.apiBaseUrl", settings.Internal.APIBaseURL},
+	} {
+		if u.value == "" {
+			continue
+		}
+		if parsed, err := url.Parse(u.value); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			errs = append(errs, utils.ConfigError(fmt.Sprintf("%s must be an absolute http(s) URL, got %q", u.name, u.value)))
+		}
+	}
+	// Mobile apps redirect to custom schemes and app links, so any absolute
+	// URL is accepted.
+	if uri := settings.RedirectURI; uri != "" {
+		if parsed, err := url.Parse(uri); err != nil || !parsed.IsAbs() {
+			errs = append(errs, utils.ConfigError(fmt.Sprintf("redirectUri must be an absolute URL, got %q", uri)))
+		}
+	}
+	switch auth := settings.ClientAuth; auth.Method {
+	case "", types.ClientSecretBasic, types.TLSClientAuth:
+	case types.PrivateKeyJWT:
+		if auth.PrivateKey == nil {
+			errs = append(errs, utils.ConfigError("clientAuth.privateKey is required for private_key_jwt"))
+		}
+	default:
+		errs = append(errs, utils.ConfigError(fmt.Sprintf("clientAuth.method %q is not supported", auth.Method)))
+	}
+	switch settings.CIBAMode {
+	case "", types.CIBAPoll, types.CIBAPing, types.CIBAPush:
+	default:
+		errs = append(errs, utils.ConfigError(fmt.Sprintf("cibaMode %q is not supported", settings.CIBAMode)))
+	}
+	if r := settings.Retry; r != nil {
+		if r.InitialBackoff < 0 || r.MaxBackoff < 0 {
+			errs = append(errs, utils.ConfigError("retry backoff must not be negative"))
+		}
+		if r.Multiplier < 0 {
+			errs = append(errs, utils.ConfigError("retry multiplier must not be negative"))
+		}
+		if r.Jitter < 0 || r.Jitter > 1 {
+			errs = append(errs, utils.ConfigError("retry jitter must be between 0 and 1"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// newClient validates settings and builds the client and its services.
+// Environment variables are only read when useEnv is set.
+func newClient(mergedSettings types.GlideSdkSettings, useEnv bool) (*GlideClient, error) {
+	if err := validateSettings(mergedSettings); err != nil {
+		return nil, err
+	}
+
+	// Present the client certificate on every request for tls_client_auth, so
+	// that certificate-bound tokens are accepted by the APIs as well
+	if auth := mergedSettings.ClientAuth; auth.Method == types.TLSClientAuth && auth.Certificate != nil {
+		client, err := utils.NewMTLSClient(mergedSettings.HTTPClient, *auth.Certificate)
+		if err != nil {
+			return nil, err
+		}
+		mergedSettings.HTTPClient = client
+	}
+
+	// Logging is configured per client: every service builds its logger from
+	// mergedSettings, so clients with different log settings do not interfere.
 
-	if mergedSettings.ClientID == "" {
-		return nil, errors.New("clientId is required")
+	// Resolve endpoints through one discovery cache, loaded in the background
+	// so that the first requests usually find it ready
+	if mergedSettings.Endpoints == nil {
+		discovery := utils.NewDiscovery(mergedSettings)
+		mergedSettings.Endpoints = discovery
+		if mergedSettings.UseDiscovery {
+			go discovery.Document(context.Background())
+		}
 	}
 
-	if mergedSettings.Internal.AuthBaseURL == "" {
-		return nil, errors.New("internal.authBaseUrl is unset")
+	// Share a single token cache between all services of this client
+	if mergedSettings.TokenSource == nil {
+		mergedSettings.TokenSource = utils.NewTokenManager(mergedSettings)
 	}
 
-	// Initialize logger with the merged log level
-	utils.SetLogLevel(utils.LogLevel(mergedSettings.Internal.LogLevel))
+	// Share the issuer's discovery document and signing keys as well
+	if mergedSettings.IDTokenVerifier == nil {
+		mergedSettings.IDTokenVerifier = utils.NewIDTokenVerifier(mergedSettings)
+	}
+
+	// Route CIBA ping and push notifications to the waiting user clients
+	if mergedSettings.CIBANotifier == nil {
+		mergedSettings.CIBANotifier = utils.NewCIBANotifications()
+	}
+
+	// Report funnel metrics in the background so they never delay API calls
+	if mergedSettings.Metrics == nil {
+		mergedSettings.Metrics = defaultMetricsSink(mergedSettings, useEnv)
+	}
+
+	// Deliver lifecycle events to the configured observer and subscribers
+	observers := utils.NewObservers()
+	if mergedSettings.Observer != nil {
+		observers.Add(mergedSettings.Observer)
+	}
+	mergedSettings.Observer = observers
 
 	client := &GlideClient{
 		Settings:     mergedSettings,
@@ -80,11 +207,48 @@ func NewGlideClient(settings types.GlideSdkSettings) (*GlideClient, error) {
 		SimSwap:      services.NewSimSwapClient(mergedSettings),
 		NumberVerify: services.NewNumberVerifyClient(mergedSettings),
 		KYCMatch:     services.NewKYCMatchClient(mergedSettings),
+		observers:    observers,
 	}
 
 	return client, nil
 }
 
+// Subscribe registers observer for the verification lifecycle events of all
+// services and returns a function that unregisters it.
+func (c *GlideClient) Subscribe(observer types.Observer) (unsubscribe func()) {
+	return c.observers.Add(observer)
+}
+
+// CIBANotificationHandler serves the client notification endpoint registered
+// for CIBA ping and push modes, resolving the SIM swap and KYC match user
+// clients waiting for approval.
+func (c *GlideClient) CIBANotificationHandler() http.Handler {
+	return utils.CIBANotificationHandler(c.Settings.CIBANotifier)
+}
+
+// Flush delivers metrics buffered by the client's metrics sink.
+func (c *GlideClient) Flush(ctx context.Context) error {
+	return c.Settings.Metrics.Flush(ctx)
+}
+
+// Close flushes and stops the client's metrics sink. The client must not be
+// used afterwards.
+func (c *GlideClient) Close(ctx context.Context) error {
+	return c.Settings.Metrics.Close(ctx)
+}
+
+// defaultMetricsSink posts metrics to REPORT_METRIC_URL when useEnv is set
+// and the variable is present, and drops them otherwise.
+func defaultMetricsSink(settings types.GlideSdkSettings, useEnv bool) types.MetricsSink {
+	if metricURL := os.Getenv("REPORT_METRIC_URL"); useEnv && metricURL != "" {
+		return utils.NewHTTPMetricsSink(metricURL, utils.HTTPMetricsSinkOptions{
+			Client: settings.HTTPClient,
+			Logger: utils.NewLogger(settings),
+		})
+	}
+	return utils.NoopMetricsSink{}
+}
+
 func getEnvOrDefault(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
@@ -113,5 +277,57 @@ func mergeSettings(defaults, override types.GlideSdkSettings) types.GlideSdkSett
 	if override.Internal.LogLevel > types.UNSET {
 		result.Internal.LogLevel = override.Internal.LogLevel
 	}
+	if override.UseDiscovery {
+		result.UseDiscovery = true
+	}
+	if override.DiscoveryRefreshInterval > 0 {
+		result.DiscoveryRefreshInterval = override.DiscoveryRefreshInterval
+	}
+	if override.Endpoints != nil {
+		result.Endpoints = override.Endpoints
+	}
+	if override.Credentials != nil {
+		result.Credentials = override.Credentials
+	}
+	if override.ClientAuth.Method != "" {
+		result.ClientAuth = override.ClientAuth
+	}
+	if override.HTTPClient != nil {
+		result.HTTPClient = override.HTTPClient
+	}
+	if override.Retry != nil {
+		result.Retry = override.Retry
+	}
+	if override.Logger != nil {
+		result.Logger = override.Logger
+	}
+	result.DisableLogRedaction = override.DisableLogRedaction
+	if override.TokenSource != nil {
+		result.TokenSource = override.TokenSource
+	}
+	if override.TokenStore != nil {
+		result.TokenStore = override.TokenStore
+	}
+	if override.Metrics != nil {
+		result.Metrics = override.Metrics
+	}
+	if override.TracerProvider != nil {
+		result.TracerProvider = override.TracerProvider
+	}
+	if override.Collector != nil {
+		result.Collector = override.Collector
+	}
+	if override.IDTokenVerifier != nil {
+		result.IDTokenVerifier = override.IDTokenVerifier
+	}
+	if override.Observer != nil {
+		result.Observer = override.Observer
+	}
+	if override.CIBAMode != "" {
+		result.CIBAMode = override.CIBAMode
+	}
+	if override.CIBANotifier != nil {
+		result.CIBANotifier = override.CIBANotifier
+	}
 	return result
 }
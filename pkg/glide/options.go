@@ -0,0 +1,218 @@
+package glide
+
+import (
+	"crypto"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Option configures a client built by New.
+type Option func(*options)
+
+type options struct {
+	useEnv bool
+	files  []string
+	set    []func(*types.GlideSdkSettings)
+}
+
+// settingsOption returns an Option that applies set to the settings once the
+// defaults are known.
+func settingsOption(set func(*types.GlideSdkSettings)) Option {
+	return func(o *options) {
+		o.set = append(o.set, set)
+	}
+}
+
+// New builds a GlideClient from, in increasing precedence, the built-in
+// defaults, GLIDE_* env vars (unless WithoutEnv is given), config files and
+// the remaining opts, applied in order. Unlike NewGlideClient, options
+// overwrite values even when empty. All configuration problems are returned
+// at once, joined.
+func New(opts ...Option) (*GlideClient, error) {
+	o := &options{useEnv: true}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+	settings := defaultSettings(o.useEnv)
+	var errs []error
+	for _, path := range o.files {
+		file, err := LoadConfigFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		settings = mergeSettings(settings, file)
+	}
+	for _, set := range o.set {
+		set(&settings)
+	}
+	// newClient validates the settings; only report them here alongside
+	// config file errors.
+	if len(errs) > 0 {
+		return nil, errors.Join(append(errs, validateSettings(settings))...)
+	}
+	return newClient(settings, o.useEnv)
+}
+
+// WithoutEnv ignores GLIDE_* and REPORT_METRIC_URL env vars.
+func WithoutEnv() Option {
+	return func(o *options) {
+		o.useEnv = false
+	}
+}
+
+// WithCredentials sets the OAuth client ID and secret.
+func WithCredentials(clientID, clientSecret string) Option {
+	return settingsOption(func(s *types.GlideSdkSettings) {
+		s.ClientID = clientID
+		s.ClientSecret = clientSecret
+	})
+}
+
+// WithCredentialsProvider sets the provider consulted for the client ID and
+// secret on every token request.
+func WithCredentialsProvider(provider types.CredentialsProvider) Option {
+	return settingsOption(func(s *types.GlideSdkSettings) {
+		s.Credentials = provider
+	})
+}
+
+// WithPrivateKeyJWT authenticates token requests with client assertions
+// signed by key (RFC 7523). keyID may be empty.
+func WithPrivateKeyJWT(key crypto.Signer, keyID string) Option {
+	return settingsOption(func(s *types.GlideSdkSettings) {
+		s.ClientAuth = types.ClientAuth{Method: types.PrivateKeyJWT, PrivateKey: key, KeyID: keyID}
+	})
+}
+
+// WithTLSClientAuth authenticates with cert over mutual TLS (RFC 8705). The
+// certificate is presented on API requests as well.
+func WithTLSClientAuth(cert tls.Certificate) Option {
+	return settingsOption(func(s *types.GlideSdkSettings) {
+		s.ClientAuth = types.ClientAuth{Method: types.TLSClientAuth, Certificate: &cert}
+	})
+}
+
+// WithRedirectURI sets the redirect URI used by Number Verify.
+func WithRedirectURI(redirectURI string) Option {
+	return settingsOption(func(s *types.GlideSdkSettings) {
+		s.RedirectURI = redirectURI
+	})
+}
+
+// WithBaseURLs sets the authorization server and API base URLs.
+func WithBaseURLs(authBaseURL, apiBaseURL string) Option {
+	return settingsOption(func(s *types.GlideSdkSettings) {
+		s.Internal.AuthBaseURL = authBaseURL
+		s.Internal.APIBaseURL = apiBaseURL
+	})
+}
+
+// WithDiscovery loads the authorization server's endpoints from its OpenID
+// configuration, reloading it every refreshInterval; zero selects one hour.
+func WithDiscovery(refreshInterval time.Duration) Option {
+	return settingsOption(func(s *types.GlideSdkSettings) {
+		s.UseDiscovery = true
+		s.DiscoveryRefreshInterval = refreshInterval
+	})
+}
+
+// WithEndpoints sets the source of the authorization server's endpoints.
+func WithEndpoints(source types.EndpointSource) Option {
+	return settingsOption(func(s *types.GlideSdkSettings) {
+		s.Endpoints = source
+	})
+}
+
+// WithCIBAMode sets the CIBA token delivery mode registered for the client.
+func WithCIBAMode(mode types.CIBAMode) Option {
+	return settingsOption(func(s *types.GlideSdkSettings) {
+		s.CIBAMode = mode
+	})
+}
+
+// WithHTTPClient sets the client used for all requests; nil selects the
+// package default.
+func WithHTTPClient(client *http.Client) Option {
+	return settingsOption(func(s *types.GlideSdkSettings) {
+		s.HTTPClient = client
+	})
+}
+
+// WithLogger sets the logger; nil logs to stderr at the configured level.
+func WithLogger(logger *slog.Logger) Option {
+	return settingsOption(func(s *types.GlideSdkSettings) {
+		s.Logger = logger
+	})
+}
+
+// WithLogLevel sets the level of the default stderr logger.
+func WithLogLevel(level types.LogLevel) Option {
+	return settingsOption(func(s *types.GlideSdkSettings) {
+		s.Internal.LogLevel = level
+	})
+}
+
+// WithRetry sets the retry policy; nil disables retries.
+func WithRetry(policy *types.RetryPolicy) Option {
+	return settingsOption(func(s *types.GlideSdkSettings) {
+		s.Retry = policy
+	})
+}
+
+// WithTokenSource sets the source of client credentials tokens.
+func WithTokenSource(source types.TokenSource) Option {
+	return settingsOption(func(s *types.GlideSdkSettings) {
+		s.TokenSource = source
+	})
+}
+
+// WithTokenStore shares minted tokens through store.
+func WithTokenStore(store types.TokenStore) Option {
+	return settingsOption(func(s *types.GlideSdkSettings) {
+		s.TokenStore = store
+	})
+}
+
+// WithMetricsSink sets the sink receiving funnel metrics.
+func WithMetricsSink(sink types.MetricsSink) Option {
+	return settingsOption(func(s *types.GlideSdkSettings) {
+		s.Metrics = sink
+	})
+}
+
+// WithCollector sets the collector of request and token metrics.
+func WithCollector(collector types.MetricsCollector) Option {
+	return settingsOption(func(s *types.GlideSdkSettings) {
+		s.Collector = collector
+	})
+}
+
+// WithTracerProvider sets the provider of the SDK's spans.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return settingsOption(func(s *types.GlideSdkSettings) {
+		s.TracerProvider = provider
+	})
+}
+
+// WithIDTokenVerifier sets the verifier of ID tokens.
+func WithIDTokenVerifier(verifier types.IDTokenVerifier) Option {
+	return settingsOption(func(s *types.GlideSdkSettings) {
+		s.IDTokenVerifier = verifier
+	})
+}
+
+// WithObserver sets the observer of verification lifecycle events.
+func WithObserver(observer types.Observer) Option {
+	return settingsOption(func(s *types.GlideSdkSettings) {
+		s.Observer = observer
+	})
+}
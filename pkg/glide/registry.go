@@ -0,0 +1,164 @@
+package glide
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/GlideApis/sdk-go/pkg/utils"
+)
+
+// TenantResolver returns the settings of a tenant, typically its client ID,
+// secret and redirect URI. It is called when the tenant's client is first
+// needed and again after the tenant is evicted.
+type TenantResolver func(ctx context.Context, tenant string) (types.GlideSdkSettings, error)
+
+// Registry lazily creates and caches one GlideClient per tenant. All tenants
+// share the base settings' HTTP client, token store, metrics sink, collector,
+// tracer provider and observer unless their own settings override them.
+type Registry struct {
+	base    types.GlideSdkSettings
+	resolve TenantResolver
+
+	mu      sync.Mutex
+	tenants map[string]*tenantEntry
+}
+
+type tenantEntry struct {
+	client *GlideClient
+	err    error
+	ready  chan struct{}
+}
+
+// NewRegistry returns a registry resolving tenants with resolve. Tenants
+// never read GLIDE_* env vars; base.UseEnv only enables REPORT_METRIC_URL.
+// base.TokenSource, base.IDTokenVerifier and base.Endpoints are ignored since
+// they belong to a single client; tokens are shared through base.TokenStore,
+// which defaults to an in-memory store, and CIBA notifications are routed by
+// base.CIBANotifier, which all tenants share.
+func NewRegistry(base types.GlideSdkSettings, resolve TenantResolver) *Registry {
+	base.TokenSource = nil
+	if base.TokenStore == nil {
+		base.TokenStore = utils.NewMemoryTokenStore()
+	}
+	if base.Collector == nil {
+		base.Collector = utils.NewMemoryCollector()
+	}
+	if base.CIBANotifier == nil {
+		base.CIBANotifier = utils.NewCIBANotifications()
+	}
+	if base.Metrics == nil {
+		base.Metrics = defaultMetricsSink(base, base.UseEnv)
+	}
+	return &Registry{
+		base:    base,
+		resolve: resolve,
+		tenants: map[string]*tenantEntry{},
+	}
+}
+
+// Client returns the client of tenant, creating it on first use. Concurrent
+// callers share a single creation; failures are not cached.
+func (r *Registry) Client(ctx context.Context, tenant string) (*GlideClient, error) {
+	r.mu.Lock()
+	entry, ok := r.tenants[tenant]
+	if !ok {
+		entry = &tenantEntry{ready: make(chan struct{})}
+		r.tenants[tenant] = entry
+		r.mu.Unlock()
+		entry.client, entry.err = r.build(ctx, tenant, nil)
+		r.mu.Lock()
+		if entry.err != nil && r.tenants[tenant] == entry {
+			delete(r.tenants, tenant)
+		}
+		r.mu.Unlock()
+		close(entry.ready)
+		return entry.client, entry.err
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-entry.ready:
+		return entry.client, entry.err
+	}
+}
+
+// Evict drops the cached client of tenant so that the next Client call
+// resolves it again. Calls already holding the old client are unaffected.
+func (r *Registry) Evict(tenant string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.tenants, tenant)
+}
+
+// Rotate replaces the client of tenant with one built from settings and
+// discards the tokens minted with the previous credentials. A later Evict
+// resolves the tenant again, so update the resolver's source as well.
+func (r *Registry) Rotate(ctx context.Context, tenant string, settings types.GlideSdkSettings) error {
+	client, err := r.build(ctx, tenant, &settings)
+	if err != nil {
+		return err
+	}
+	entry := &tenantEntry{client: client, ready: make(chan struct{})}
+	close(entry.ready)
+
+	r.mu.Lock()
+	old := r.tenants[tenant]
+	r.tenants[tenant] = entry
+	r.mu.Unlock()
+
+	if old != nil {
+		<-old.ready
+		if old.client != nil {
+			if tokens, ok := old.client.Settings.TokenSource.(*utils.TokenManager); ok {
+				tokens.InvalidateAll(ctx)
+			}
+		}
+	}
+	return nil
+}
+
+// Tenants returns the tenants with a cached client, sorted.
+func (r *Registry) Tenants() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tenants := make([]string, 0, len(r.tenants))
+	for tenant := range r.tenants {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	return tenants
+}
+
+// CIBANotificationHandler serves the client notification endpoint for all
+// tenants; see GlideClient.CIBANotificationHandler.
+func (r *Registry) CIBANotificationHandler() http.Handler {
+	return utils.CIBANotificationHandler(r.base.CIBANotifier)
+}
+
+// Close flushes and stops the shared metrics sink.
+func (r *Registry) Close(ctx context.Context) error {
+	return r.base.Metrics.Close(ctx)
+}
+
+// build creates the client of tenant from settings, or from the resolver
+// when settings is nil.
+func (r *Registry) build(ctx context.Context, tenant string, settings *types.GlideSdkSettings) (*GlideClient, error) {
+	if settings == nil {
+		resolved, err := r.resolve(ctx, tenant)
+		if err != nil {
+			return nil, err
+		}
+		settings = &resolved
+	}
+	merged := mergeSettings(mergeSettings(defaultSettings(false), r.base), *settings)
+	merged.DisableLogRedaction = r.base.DisableLogRedaction || settings.DisableLogRedaction
+	merged.TokenSource = settings.TokenSource
+	merged.IDTokenVerifier = settings.IDTokenVerifier
+	merged.Endpoints = settings.Endpoints
+	return newClient(merged, false)
+}
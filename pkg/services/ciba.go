@@ -0,0 +1,390 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/GlideApis/sdk-go/pkg/utils"
+)
+
+const (
+	// defaultCIBAInterval is the polling interval when the backchannel
+	// authentication response does not set one (CIBA Core 7.3).
+	defaultCIBAInterval = 5 * time.Second
+	// cibaSlowDownStep is added to the interval on slow_down.
+	cibaSlowDownStep = 5 * time.Second
+	// defaultCIBATokenLifetime is the lifetime of pushed tokens carrying
+	// neither expires_in nor an exp claim.
+	defaultCIBATokenLifetime = 10 * time.Minute
+)
+
+// authReqIDClaim is the ID token claim binding pushed tokens to their
+// request (CIBA Core 10.3.1).
+const authReqIDClaim = "urn:openid:params:jwt:claim:auth_req_id"
+
+// cibaRequest is a pending backchannel authentication request.
+type cibaRequest struct {
+	authReqID  string
+	consentURL string
+	interval   time.Duration
+	expiresAt  time.Time
+	// notification is set in ping and push modes.
+	notification *cibaNotification
+}
+
+// close stops routing notifications to the request.
+func (r *cibaRequest) close() {
+	if r.notification != nil {
+		r.notification.unregister()
+	}
+}
+
+// cibaNotification receives the ping or push notification of a request.
+type cibaNotification struct {
+	done       chan struct{}
+	unregister func()
+
+	mu        sync.Mutex
+	authReqID string
+	received  *types.CIBANotification
+}
+
+func (n *cibaNotification) deliver(ctx context.Context, notification types.CIBANotification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.authReqID != "" && !utils.SecureCompare(notification.AuthReqID, n.authReqID) {
+		return utils.InvalidRequestError("auth_req_id does not match the request")
+	}
+	if n.received == nil {
+		n.received = &notification
+		close(n.done)
+	}
+	return nil
+}
+
+// randomToken returns a random URL-safe token, e.g. a
+// client_notification_token or consent state.
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// startCIBA sends a backchannel authentication request for the scope api.
+// In ping and push modes it registers the request with the CIBA notifier.
+func startCIBA(ctx context.Context, settings types.GlideSdkSettings, logger *slog.Logger, api, loginHint string) (_ *cibaRequest, err error) {
+	data := url.Values{}
+	data.Set("scope", api)
+	if loginHint != "" {
+		data.Set("login_hint", loginHint)
+	}
+	var notification *cibaNotification
+	if settings.CIBAMode == types.CIBAPing || settings.CIBAMode == types.CIBAPush {
+		if settings.CIBANotifier == nil {
+			return nil, utils.ConfigError("a CIBA notifier is required in ping and push modes")
+		}
+		token, err := randomToken()
+		if err != nil {
+			return nil, fmt.Errorf("[GlideClient] Failed to generate client notification token: %w", err)
+		}
+		notification = &cibaNotification{done: make(chan struct{})}
+		// Register before sending, as the notification may arrive before
+		// the response.
+		notification.unregister = settings.CIBANotifier.Register(token, notification.deliver)
+		defer func() {
+			if err != nil {
+				notification.unregister()
+			}
+		}()
+		data.Set("client_notification_token", token)
+	}
+	headers := map[string]string{"Content-Type": "application/x-www-form-urlencoded"}
+	if err := utils.AuthenticateClient(ctx, settings, headers, data); err != nil {
+		return nil, err
+	}
+	resp, err := utils.FetchXWithContext(ctx, utils.EndpointsFor(ctx, settings).BackchannelAuthenticationEndpoint, utils.FetchXInput{
+		Client:    settings.HTTPClient,
+		Logger:    logger,
+		Retry:     settings.Retry,
+		Collector: settings.Collector,
+		Method:    "POST",
+		Headers:   headers,
+		Body:      data.Encode(),
+	})
+	if err != nil {
+		return nil, utils.NewAPIError(api, "backchannel-authentication", err, nil)
+	}
+	var body struct {
+		ConsentURL string `json:"consentUrl"`
+		AuthReqID  string `json:"auth_req_id"`
+		ExpiresIn  int64  `json:"expires_in"`
+		Interval   int64  `json:"interval"`
+	}
+	if err := resp.JSON(&body); err != nil {
+		return nil, fmt.Errorf("[GlideClient] Failed to parse response: %w", err)
+	}
+	req := &cibaRequest{authReqID: body.AuthReqID, consentURL: body.ConsentURL, interval: defaultCIBAInterval, notification: notification}
+	if notification != nil {
+		notification.mu.Lock()
+		notification.authReqID = body.AuthReqID
+		notification.mu.Unlock()
+	}
+	if body.Interval > 0 {
+		req.interval = time.Duration(body.Interval) * time.Second
+	}
+	// expires_in is required (CIBA Core 7.3); without it the request is
+	// still bounded, so that waiting for it cannot block forever.
+	expiresIn := utils.DefaultAuthRequestTTL
+	if body.ExpiresIn > 0 {
+		expiresIn = time.Duration(body.ExpiresIn) * time.Second
+	}
+	req.expiresAt = time.Now().Add(expiresIn)
+	emit(ctx, settings, types.Event{Type: types.EventAuthStarted, API: api, Operation: "backchannel-authentication"})
+	if body.ConsentURL != "" {
+		emit(ctx, settings, types.Event{Type: types.EventConsentRequired, API: api, Operation: "backchannel-authentication", ConsentURL: body.ConsentURL})
+	}
+	return req, nil
+}
+
+// redeemCIBA returns the session granted for req: in poll mode by polling
+// the token endpoint once, otherwise from its notification.
+func redeemCIBA(ctx context.Context, settings types.GlideSdkSettings, logger *slog.Logger, api string, req *cibaRequest) (*types.Session, error) {
+	if req.notification == nil {
+		return exchangeCIBA(ctx, settings, logger, api, req)
+	}
+	return awaitCIBA(ctx, settings, logger, api, req)
+}
+
+// awaitNotification blocks until the notification of req arrives, req
+// expires or ctx is done, and returns ctx's error in the latter case. It
+// returns at once in poll mode. It does not touch the user client, so
+// callers wait without holding its lock.
+func (r *cibaRequest) awaitNotification(ctx context.Context) error {
+	if r.notification == nil {
+		return nil
+	}
+	var expired <-chan time.Time
+	if !r.expiresAt.IsZero() {
+		timer := time.NewTimer(time.Until(r.expiresAt))
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-expired:
+	case <-r.notification.done:
+	}
+	return nil
+}
+
+// awaitCIBA redeems the notification of req, reporting it as pending when
+// it has not arrived yet; it never blocks, so wait with awaitNotification
+// first. A ping is followed by a token request; a push carries the tokens,
+// whose ID token must be bound to req.
+func awaitCIBA(ctx context.Context, settings types.GlideSdkSettings, logger *slog.Logger, api string, req *cibaRequest) (*types.Session, error) {
+	select {
+	case <-req.notification.done:
+	default:
+		if !req.expiresAt.IsZero() && !time.Now().Before(req.expiresAt) {
+			return nil, utils.AuthorizationExpiredError(api)
+		}
+		return nil, utils.AuthorizationPendingError(api)
+	}
+	req.notification.mu.Lock()
+	notification := *req.notification.received
+	req.notification.mu.Unlock()
+	if !utils.SecureCompare(notification.AuthReqID, req.authReqID) {
+		return nil, utils.InvalidRequestError("auth_req_id does not match the request")
+	}
+	if notification.Error != "" {
+		return nil, utils.AuthorizationResponseError(api, notification.Error, notification.ErrorDescription)
+	}
+	if settings.CIBAMode != types.CIBAPush {
+		return exchangeCIBA(ctx, settings, logger, api, req)
+	}
+
+	if notification.AccessToken == "" {
+		return nil, utils.InvalidTokenError("push notification has no access token")
+	}
+	// The ID token is required in push mode, as it binds the tokens to the
+	// request (CIBA Core 10.3.1).
+	if notification.IDToken == "" {
+		return nil, utils.InvalidTokenError("push notification has no ID token")
+	}
+	claims, err := idTokenVerifier(settings).Verify(ctx, notification.IDToken, "")
+	if err != nil {
+		return nil, err
+	}
+	if bound, _ := claims.Raw[authReqIDClaim].(string); !utils.SecureCompare(bound, req.authReqID) {
+		return nil, utils.InvalidTokenError("ID token is not bound to the authentication request")
+	}
+	session := &types.Session{
+		AccessToken: notification.AccessToken,
+		ExpiresAt:   pushedTokenExpiry(notification),
+		Scopes:      []string{api},
+		IDToken:     notification.IDToken,
+	}
+	if operator, _ := utils.GetOperator(session); operator != "" {
+		emit(ctx, settings, types.Event{Type: types.EventOperatorResolved, API: api, Operation: "token", Operator: operator})
+	}
+	return session, nil
+}
+
+// pushedTokenExpiry returns when the access token of a push notification
+// expires: after expires_in, at the token's exp claim if it is a JWT, or
+// after defaultCIBATokenLifetime.
+func pushedTokenExpiry(notification types.CIBANotification) int64 {
+	if notification.ExpiresIn > 0 {
+		return time.Now().Unix() + notification.ExpiresIn
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := utils.DecodeJWTClaims(notification.AccessToken, &claims); err == nil && claims.Exp > 0 {
+		return claims.Exp
+	}
+	return time.Now().Add(defaultCIBATokenLifetime).Unix()
+}
+
+// exchangeCIBA polls the token endpoint once for req. A slow_down answer
+// increases the interval of req, so callers must serialise calls for the
+// same request.
+func exchangeCIBA(ctx context.Context, settings types.GlideSdkSettings, logger *slog.Logger, api string, req *cibaRequest) (*types.Session, error) {
+	data := url.Values{}
+	data.Set("grant_type", "urn:openid:params:grant-type:ciba")
+	data.Set("auth_req_id", req.authReqID)
+
+	headers := map[string]string{"Content-Type": "application/x-www-form-urlencoded"}
+	if err := utils.AuthenticateClient(ctx, settings, headers, data); err != nil {
+		return nil, err
+	}
+	resp, err := utils.FetchXWithContext(ctx, utils.EndpointsFor(ctx, settings).TokenEndpoint, utils.FetchXInput{
+		Client:     settings.HTTPClient,
+		Logger:     logger,
+		Retry:      settings.Retry,
+		Collector:  settings.Collector,
+		Idempotent: true,
+		Method:     "POST",
+		Headers:    headers,
+		Body:       data.Encode(),
+	})
+	if err != nil {
+		err = utils.NewAPIError(api, "token", err, nil)
+		if errors.Is(err, utils.ErrSlowDown) {
+			req.interval += cibaSlowDownStep
+		}
+		return nil, err
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+		Scope       string `json:"scope"`
+		IDToken     string `json:"id_token"`
+	}
+	if err := resp.JSON(&body); err != nil {
+		return nil, fmt.Errorf("[GlideClient] Failed to parse response: %w", err)
+	}
+	session := &types.Session{
+		AccessToken: body.AccessToken,
+		ExpiresAt:   time.Now().Unix() + body.ExpiresIn,
+		Scopes:      strings.Split(body.Scope, " "),
+		IDToken:     body.IDToken,
+	}
+	if operator, _ := utils.GetOperator(session); operator != "" {
+		emit(ctx, settings, types.Event{Type: types.EventOperatorResolved, API: api, Operation: "token", Operator: operator})
+	}
+	return session, nil
+}
+
+// cibaPending reports whether err means the request still awaits the user.
+func cibaPending(err error) bool {
+	return errors.Is(err, utils.ErrAuthorizationPending) || errors.Is(err, utils.ErrSlowDown)
+}
+
+// cibaTransient reports whether err is a failure of the poll itself, such
+// as a 5xx, a 429 or a connection error left after retries, rather than an
+// answer about the request, which then still awaits the user.
+func cibaTransient(err error) bool {
+	if errors.Is(err, utils.ErrUpstream) || errors.Is(err, utils.ErrRateLimited) {
+		return true
+	}
+	var gErr *utils.GlideError
+	return errors.As(err, &gErr) && gErr.Kind == nil && gErr.StatusCode == 0 && gErr.Err != nil
+}
+
+// cibaKept reports whether a request whose redemption failed with err must
+// be kept: it is still pending, the poll failed transiently or the caller
+// gave up. Other errors, such as access_denied, expired_token or
+// invalid_grant, end the request.
+func cibaKept(ctx context.Context, err error) bool {
+	return cibaPending(err) || cibaTransient(err) || ctx.Err() != nil
+}
+
+// cibaStatus maps the result of a poll to its CIBAStatus.
+func cibaStatus(err error) types.CIBAStatus {
+	switch {
+	case err == nil:
+		return types.CIBAApproved
+	case cibaTransient(err):
+		return types.CIBAUnavailable
+	case errors.Is(err, utils.ErrSlowDown):
+		return types.CIBASlowDown
+	case errors.Is(err, utils.ErrAuthorizationPending):
+		return types.CIBAPending
+	case errors.Is(err, utils.ErrPermissionDenied):
+		return types.CIBADenied
+	case errors.Is(err, utils.ErrAuthorizationExpired):
+		return types.CIBAExpired
+	}
+	return types.CIBAFailed
+}
+
+// waitForCIBA calls poll until it succeeds, waiting between polls as long
+// as the pending request returned by poll asks. Transient failures of a
+// poll, such as a 5xx or a connection error, are polled through as well. It
+// stops with poll's error once that is neither of those nor
+// authorization_pending or slow_down, with an ErrAuthorizationExpired error
+// once the request has expired, and with ctx's error when ctx is done first.
+func waitForCIBA(ctx context.Context, api string, opts types.CIBAPollOptions, poll func(context.Context) (cibaRequest, error)) error {
+	for attempt := 1; ; attempt++ {
+		req, err := poll(ctx)
+		pending := cibaPending(err) || (cibaTransient(err) && req.authReqID != "")
+		if pending && !req.expiresAt.IsZero() && !time.Now().Before(req.expiresAt) {
+			err, pending = utils.AuthorizationExpiredError(api), false
+		}
+		if opts.OnProgress != nil {
+			progress := types.CIBAProgress{API: api, Attempt: attempt, Status: cibaStatus(err), ExpiresAt: req.expiresAt}
+			if pending {
+				progress.Interval = req.interval
+			}
+			opts.OnProgress(progress)
+		}
+		if !pending {
+			return err
+		}
+		interval := req.interval
+		if interval <= 0 {
+			interval = defaultCIBAInterval
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
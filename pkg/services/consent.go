@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/GlideApis/sdk-go/pkg/utils"
+)
+
+// consentClient is a user client of an API authorized by backchannel
+// authentication, i.e. *SimSwapUserClient or *KYCMatchUserClient.
+type consentClient interface {
+	GetConsentURL() string
+	PollAndWaitForSessionWithOptions(ctx context.Context, opts types.CIBAPollOptions) error
+	// pendingRequest returns the request awaiting the user, if any.
+	pendingRequest() (cibaRequest, bool)
+	// resume makes req the request awaiting the user.
+	resume(req *cibaRequest)
+	// abandon drops the request awaiting the user, if any.
+	abandon()
+}
+
+// ConsentResult is the outcome of a completed consent.
+type ConsentResult[C consentClient] struct {
+	Consent *types.PendingConsent
+	// Client holds the session granted by the user.
+	Client C
+}
+
+// ConsentOptions configures a ConsentHandler.
+type ConsentOptions[C consentClient] struct {
+	// Store keeps pending consents until the user returns. It defaults to an
+	// in-memory store, which only works when the user returns to the same
+	// instance.
+	Store types.ConsentStore
+	// OnResult writes the response once the user has consented. By default
+	// a plain text confirmation is written.
+	OnResult func(w http.ResponseWriter, r *http.Request, result *ConsentResult[C])
+	// OnError writes the response for a failed consent. By default only
+	// the status text is written, with status 400 for invalid or denied
+	// requests and 502 otherwise; the error itself is logged.
+	OnError func(w http.ResponseWriter, r *http.Request, err error)
+	// Poll configures the polling for the session.
+	Poll types.CIBAPollOptions
+}
+
+// ConsentHandler runs the consent workflow of SIM swap or KYC match: Start
+// begins a backchannel authentication request and stores it under a state
+// token, and the handler serves the page the user returns to after
+// consenting, identified by the state query parameter. It waits for the
+// session and hands the client to OnResult.
+//
+// When the user returns to another instance sharing the Store, the request
+// is resumed there and polled, whatever the CIBA mode; push mode therefore
+// needs the user to return to the instance that started it.
+type ConsentHandler[C consentClient] struct {
+	api       string
+	logger    *slog.Logger
+	start     func(context.Context, types.UserIdentifier) (C, error)
+	newClient func(types.UserIdentifier) C
+	opts      ConsentOptions[C]
+
+	mu      sync.Mutex
+	clients map[string]localConsent[C]
+}
+
+// localConsent is a consent started by this instance.
+type localConsent[C consentClient] struct {
+	client    C
+	expiresAt time.Time
+}
+
+func newConsentHandler[C consentClient](settings types.GlideSdkSettings, api string, start func(context.Context, types.UserIdentifier) (C, error), newClient func(types.UserIdentifier) C, opts ConsentOptions[C]) *ConsentHandler[C] {
+	if opts.Store == nil {
+		opts.Store = utils.NewMemoryConsentStore()
+	}
+	if opts.OnResult == nil {
+		opts.OnResult = writeConsentResult[C]
+	}
+	if opts.OnError == nil {
+		opts.OnError = writeCallbackError
+	}
+	return &ConsentHandler[C]{
+		api:       api,
+		logger:    utils.NewLogger(settings),
+		start:     start,
+		newClient: newClient,
+		opts:      opts,
+		clients:   map[string]localConsent[C]{},
+	}
+}
+
+// Start sends a backchannel authentication request for identifier and
+// stores it until the user returns. Send the user to the consent's
+// ConsentURL when it requires consent, and have them return to the handler
+// with the consent's State.
+func (h *ConsentHandler[C]) Start(ctx context.Context, identifier types.UserIdentifier) (*Consent[C], error) {
+	client, err := h.start(ctx, identifier)
+	if err != nil {
+		return nil, err
+	}
+	req, ok := client.pendingRequest()
+	if !ok || req.authReqID == "" {
+		return nil, fmt.Errorf("[GlideClient] Failed to start session")
+	}
+	state, err := randomToken()
+	if err != nil {
+		return nil, fmt.Errorf("[GlideClient] Failed to generate consent state: %w", err)
+	}
+	pending := &types.PendingConsent{
+		State:      state,
+		API:        h.api,
+		AuthReqID:  req.authReqID,
+		ConsentURL: req.consentURL,
+		Interval:   req.interval,
+		ExpiresAt:  req.expiresAt,
+	}
+	if pending.ExpiresAt.IsZero() {
+		pending.ExpiresAt = time.Now().Add(utils.DefaultAuthRequestTTL)
+	}
+	switch identifier := identifier.(type) {
+	case types.PhoneIdentifier:
+		pending.PhoneNumber = identifier.PhoneNumber
+	case types.IpIdentifier:
+		pending.IPAddress = identifier.IPAddress
+	case types.UserIdIdentifier:
+		pending.UserID = identifier.UserID
+	}
+	if err := h.opts.Store.Put(ctx, pending); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	h.mu.Lock()
+	for state, local := range h.clients {
+		if !now.Before(local.expiresAt) {
+			local.client.abandon()
+			delete(h.clients, state)
+		}
+	}
+	h.clients[state] = localConsent[C]{client: client, expiresAt: pending.ExpiresAt}
+	h.mu.Unlock()
+	return &Consent[C]{handler: h, state: state, client: client}, nil
+}
+
+func (h *ConsentHandler[C]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	result, err := h.handle(r)
+	if err != nil {
+		h.logger.Error("Consent return failed", "api", h.api, "error", err)
+		h.opts.OnError(w, r, err)
+		return
+	}
+	h.opts.OnResult(w, r, result)
+}
+
+func (h *ConsentHandler[C]) handle(r *http.Request) (_ *ConsentResult[C], err error) {
+	ctx := r.Context()
+	query := r.URL.Query()
+	state := query.Get("state")
+	client, local := h.takeLocal(state)
+	// The state is used up, so a failed consent cannot be completed later.
+	defer func() {
+		if err != nil && local {
+			client.abandon()
+		}
+	}()
+	var pending *types.PendingConsent
+	if state != "" {
+		if pending, err = h.opts.Store.Take(ctx, state); err != nil {
+			return nil, err
+		}
+	}
+	if code := query.Get("error"); code != "" {
+		return nil, utils.AuthorizationResponseError(h.api, code, query.Get("error_description"))
+	}
+	if pending == nil || pending.API != h.api {
+		return nil, utils.InvalidRequestError("Unknown or expired state")
+	}
+	if !local {
+		client = h.resume(pending)
+	}
+	if err := client.PollAndWaitForSessionWithOptions(ctx, h.opts.Poll); err != nil {
+		return nil, err
+	}
+	return &ConsentResult[C]{Consent: pending, Client: client}, nil
+}
+
+// resume rebuilds the client of a consent started by another instance.
+func (h *ConsentHandler[C]) resume(pending *types.PendingConsent) C {
+	var identifier types.UserIdentifier
+	switch {
+	case pending.PhoneNumber != "":
+		identifier = types.PhoneIdentifier{PhoneNumber: pending.PhoneNumber}
+	case pending.IPAddress != "":
+		identifier = types.IpIdentifier{IPAddress: pending.IPAddress}
+	case pending.UserID != "":
+		identifier = types.UserIdIdentifier{UserID: pending.UserID}
+	}
+	client := h.newClient(identifier)
+	client.resume(&cibaRequest{
+		authReqID:  pending.AuthReqID,
+		consentURL: pending.ConsentURL,
+		interval:   pending.Interval,
+		expiresAt:  pending.ExpiresAt,
+	})
+	return client
+}
+
+// takeLocal removes and returns the client of state if this instance
+// started it.
+func (h *ConsentHandler[C]) takeLocal(state string) (C, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	local, ok := h.clients[state]
+	delete(h.clients, state)
+	return local.client, ok
+}
+
+// Consent is a consent started by ConsentHandler.Start.
+type Consent[C consentClient] struct {
+	handler *ConsentHandler[C]
+	state   string
+	client  C
+}
+
+// ConsentURL returns the URL to send the user to, empty when the request
+// does not require consent.
+func (c *Consent[C]) ConsentURL() string {
+	return c.client.GetConsentURL()
+}
+
+// State returns the token identifying the consent when the user returns.
+func (c *Consent[C]) State() string {
+	return c.state
+}
+
+// Client returns the user client, which holds the session once the user has
+// consented.
+func (c *Consent[C]) Client() C {
+	return c.client
+}
+
+// Wait polls for the session on this instance until the user consents,
+// denies or the request expires, or ctx is done. Once the session is granted
+// the consent is forgotten, so that the handler rejects its state. Use
+// either Wait or the handler to complete a consent across instances, as the
+// request can only be redeemed once.
+func (c *Consent[C]) Wait(ctx context.Context) error {
+	if err := c.client.PollAndWaitForSessionWithOptions(ctx, c.handler.opts.Poll); err != nil {
+		return err
+	}
+	c.handler.takeLocal(c.state)
+	_, err := c.handler.opts.Store.Take(ctx, c.state)
+	return err
+}
+
+func writeConsentResult[C consentClient](w http.ResponseWriter, r *http.Request, result *ConsentResult[C]) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "Consent granted, you can close this page.")
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/types"
+)
+
+// emit sends event to the observer configured in settings, if any.
+func emit(ctx context.Context, settings types.GlideSdkSettings, event types.Event) {
+	if settings.Observer == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	settings.Observer.OnEvent(ctx, event)
+}
+
+// emitFailure reports err as an EventFailed when it is not nil.
+func emitFailure(ctx context.Context, settings types.GlideSdkSettings, api, operation, sessionID string, err error) {
+	if err == nil {
+		return
+	}
+	emit(ctx, settings, types.Event{Type: types.EventFailed, API: api, Operation: operation, SessionID: sessionID, Err: err})
+}
+
+// verificationResult maps a verification outcome to its event type.
+func verificationResult(verified bool) types.EventType {
+	if verified {
+		return types.EventVerified
+	}
+	return types.EventUnverified
+}
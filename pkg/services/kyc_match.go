@@ -1,51 +1,95 @@
 package services
 
 import (
-	"encoding/base64"
+	"context"
 	"encoding/json"
 	"fmt"
-	"net/url"
-	"strings"
+	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/GlideApis/sdk-go/pkg/types"
 	"github.com/GlideApis/sdk-go/pkg/utils"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type KYCMatchUserClient struct {
 	settings        types.GlideSdkSettings
+	logger          *slog.Logger
+	tracer          trace.Tracer
+	metrics         types.MetricsSink
+	mu              sync.Mutex // guards session, consent and authReq
+	verifier        types.IDTokenVerifier
 	identifier      types.UserIdentifier
 	session         *types.Session
 	RequiresConsent bool
 	consentURL      string
-	authReqID       string
+	authReq         *cibaRequest
 }
 
 func NewKYCMatchUserClient(settings types.GlideSdkSettings, identifier types.UserIdentifier) *KYCMatchUserClient {
 	return &KYCMatchUserClient{
 		settings:   settings,
+		logger:     utils.NewLogger(settings),
+		tracer:     utils.NewTracer(settings),
+		metrics:    metricsSink(settings),
+		verifier:   idTokenVerifier(settings),
 		identifier: identifier,
 	}
 }
 
+// NeedsConsent reports whether the user must consent at GetConsentURL. Use
+// it instead of the RequiresConsent field while the client is shared with
+// other goroutines.
+func (c *KYCMatchUserClient) NeedsConsent() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.RequiresConsent
+}
+
 func (c *KYCMatchUserClient) GetConsentURL() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.consentURL
 }
 
+// IDTokenClaims verifies the ID token returned with the session's access
+// token and returns its claims. The session must have been started.
+func (c *KYCMatchUserClient) IDTokenClaims(ctx context.Context) (*types.IDTokenClaims, error) {
+	c.mu.Lock()
+	session := c.session
+	c.mu.Unlock()
+	if session == nil || session.IDToken == "" {
+		return nil, utils.InvalidRequestError("Session has no ID token")
+	}
+	return c.verifier.Verify(ctx, session.IDToken, "")
+}
+
 func (c *KYCMatchUserClient) Match(props types.KYCMatchProps, conf types.ApiConfig) (*types.KYCMatchResponse, error) {
-	var wg sync.WaitGroup
+	return c.MatchWithContext(context.Background(), props, conf)
+}
+
+// MatchWithContext is like Match but bound to ctx.
+func (c *KYCMatchUserClient) MatchWithContext(ctx context.Context, props types.KYCMatchProps, conf types.ApiConfig) (res *types.KYCMatchResponse, err error) {
+	ctx, span := utils.StartSpan(ctx, c.tracer, "kyc-match", "match")
+	defer func() { utils.EndSpan(span, err) }()
+	start := time.Now()
+	defer func() {
+		utils.LogOperation(ctx, c.logger, "kyc-match", "match", start, err)
+		emitFailure(ctx, c.settings, "kyc-match", "match", conf.SessionIdentifier, err)
+	}()
 	if c.settings.Internal.APIBaseURL == "" {
-		return nil, fmt.Errorf("[GlideClient] internal.apiBaseUrl is unset")
+		return nil, utils.ConfigError("internal.apiBaseUrl is unset")
 	}
 	if conf.SessionIdentifier != "" {
-		c.reportKYCMatchMetric(&wg, conf.SessionIdentifier, "Glide start", "")
+		c.reportKYCMatchMetric(ctx, conf.SessionIdentifier, "Glide start", "")
 	}
 
-	session, err := c.getSession(conf.Session)
+	session, err := c.getSession(ctx, conf.Session)
 	if err != nil {
 		return nil, err
 	}
+	utils.SetSpanSessionOperator(ctx, session)
 
 	data := map[string]interface{}{
 		"phoneNumber":          props.PhoneNumber,
@@ -75,8 +119,13 @@ func (c *KYCMatchUserClient) Match(props types.KYCMatchProps, conf types.ApiConf
 		return nil, err
 	}
 
-	resp, err := utils.FetchX(c.settings.Internal.APIBaseURL+"/kyc-match/match", utils.FetchXInput{
-		Method: "POST",
+	resp, err := utils.FetchXWithContext(ctx, c.settings.Internal.APIBaseURL+"/kyc-match/match", utils.FetchXInput{
+		Client:     c.settings.HTTPClient,
+		Logger:     c.logger,
+		Retry:      c.settings.Retry,
+		Collector:  c.settings.Collector,
+		Idempotent: true,
+		Method:     "POST",
 		Headers: map[string]string{
 			"Content-Type":  "application/json",
 			"Authorization": "Bearer " + session.AccessToken,
@@ -85,16 +134,9 @@ func (c *KYCMatchUserClient) Match(props types.KYCMatchProps, conf types.ApiConf
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("[GlideClient]: [kyc-match] FetchX failed for match: %w", err)
+		return nil, utils.NewAPIError("kyc-match", "match", err, session)
 	}
 
-	// Add debug logging
-	var rawResponse map[string]interface{}
-	if err := resp.JSON(&rawResponse); err != nil {
-		return nil, fmt.Errorf("[GlideClient] Failed to parse raw response: %w", err)
-	}
-	fmt.Printf("Debug: Raw KYC Match response: %+v\n", rawResponse)
-
 	var result types.KYCMatchResponse
 	if err := resp.JSON(&result); err != nil {
 		return nil, fmt.Errorf("[GlideClient] Failed to parse response: %w", err)
@@ -130,16 +172,31 @@ func (c *KYCMatchUserClient) Match(props types.KYCMatchProps, conf types.ApiConf
 	setDefault(&result.GenderMatch)
 
 	if conf.SessionIdentifier != "" {
-		c.reportKYCMatchMetric(&wg, conf.SessionIdentifier, "Glide match complete", "")
+		c.reportKYCMatchMetric(ctx, conf.SessionIdentifier, "Glide match complete", "")
 	}
-	wg.Wait()
 	return &result, nil
 }
 
 func (c *KYCMatchUserClient) StartSession() error {
-	if c.settings.ClientID == "" || c.settings.ClientSecret == "" {
-		return fmt.Errorf("[GlideClient] Client credentials are required to generate a new session")
-	}
+	return c.StartSessionWithContext(context.Background())
+}
+
+// StartSessionWithContext is like StartSession but bound to ctx.
+func (c *KYCMatchUserClient) StartSessionWithContext(ctx context.Context) (err error) {
+	ctx, span := utils.StartSpan(ctx, c.tracer, "kyc-match", "backchannel-authentication")
+	defer func() { utils.EndSpan(span, err) }()
+	start := time.Now()
+	defer func() {
+		utils.LogOperation(ctx, c.logger, "kyc-match", "backchannel-authentication", start, err)
+		emitFailure(ctx, c.settings, "kyc-match", "backchannel-authentication", "", err)
+	}()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.startSession(ctx)
+}
+
+// startSession starts a backchannel authentication request; c.mu must be held.
+func (c *KYCMatchUserClient) startSession(ctx context.Context) error {
 	var loginHint string
 	switch identifier := c.identifier.(type) {
 	case types.PhoneIdentifier:
@@ -147,48 +204,46 @@ func (c *KYCMatchUserClient) StartSession() error {
 	case types.IpIdentifier:
 		loginHint = "ipport:" + identifier.IPAddress
 	}
-	data := url.Values{}
-	data.Set("scope", "kyc-match")
-	if loginHint != "" {
-		data.Set("login_hint", loginHint)
-	}
-	resp, err := utils.FetchX(c.settings.Internal.AuthBaseURL+"/oauth2/backchannel-authentication", utils.FetchXInput{
-		Method: "POST",
-		Headers: map[string]string{
-			"Content-Type":  "application/x-www-form-urlencoded",
-			"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(c.settings.ClientID+":"+c.settings.ClientSecret)),
-		},
-		Body: data.Encode(),
-	})
+	req, err := startCIBA(ctx, c.settings, c.logger, "kyc-match", loginHint)
 	if err != nil {
-		return fmt.Errorf("[GlideClient] FetchX failed: %w", err)
-	}
-	var body struct {
-		ConsentURL string `json:"consentUrl"`
-		AuthReqID  string `json:"auth_req_id"`
+		return err
 	}
-	if err := resp.JSON(&body); err != nil {
-		return fmt.Errorf("[GlideClient] Failed to parse response: %w", err)
-	}
-	if body.ConsentURL != "" {
+	if req.consentURL != "" {
 		c.RequiresConsent = true
-		c.consentURL = body.ConsentURL
+		c.consentURL = req.consentURL
 	}
-	c.authReqID = body.AuthReqID
-
+	c.authReq = req
 	return nil
 }
 
-func (c *KYCMatchUserClient) getSession(confSession *types.Session) (*types.Session, error) {
+func (c *KYCMatchUserClient) getSession(ctx context.Context, confSession *types.Session) (*types.Session, error) {
 	if confSession != nil {
 		return confSession, nil
 	}
 
-	if c.session != nil && c.session.ExpiresAt > time.Now().Add(time.Minute).Unix() && contains(c.session.Scopes, "kyc-match") {
-		return c.session, nil
+	// Ping and push notifications are awaited without the lock, so that
+	// the client stays usable while the user decides.
+	c.mu.Lock()
+	session, req := c.cachedSession(), c.authReq
+	c.mu.Unlock()
+	if session != nil {
+		return session, nil
+	}
+	if req != nil {
+		if err := req.awaitNotification(ctx); err != nil {
+			return nil, err
+		}
 	}
 
-	session, err := c.generateNewSession()
+	// Holding the lock across the token call makes concurrent callers wait
+	// for a single CIBA token exchange instead of each starting their own.
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if session := c.cachedSession(); session != nil {
+		return session, nil
+	}
+
+	session, err := c.generateNewSession(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -197,79 +252,110 @@ func (c *KYCMatchUserClient) getSession(confSession *types.Session) (*types.Sess
 	return session, nil
 }
 
+// cachedSession returns the session if it is valid for another minute; c.mu
+// must be held.
+func (c *KYCMatchUserClient) cachedSession() *types.Session {
+	if c.session != nil && c.session.ExpiresAt > time.Now().Add(time.Minute).Unix() && contains(c.session.Scopes, "kyc-match") {
+		return c.session
+	}
+	return nil
+}
+
+// PollAndWaitForSession polls for a session until the user approves or
+// denies the backchannel authentication request, or it expires.
 func (c *KYCMatchUserClient) PollAndWaitForSession() error {
-	for {
-		_, err := c.getSession(nil)
-		if err == nil {
-			return nil
+	return c.PollAndWaitForSessionWithContext(context.Background())
+}
+
+// PollAndWaitForSessionWithContext is like PollAndWaitForSession but stops
+// when ctx is done.
+func (c *KYCMatchUserClient) PollAndWaitForSessionWithContext(ctx context.Context) error {
+	return c.PollAndWaitForSessionWithOptions(ctx, types.CIBAPollOptions{})
+}
+
+// PollAndWaitForSessionWithOptions polls at the interval requested by the
+// authorization server, slowing down when asked to. It returns an error
+// matching utils.ErrPermissionDenied when the user denies the request and
+// utils.ErrAuthorizationExpired when it expires.
+func (c *KYCMatchUserClient) PollAndWaitForSessionWithOptions(ctx context.Context, opts types.CIBAPollOptions) error {
+	return waitForCIBA(ctx, "kyc-match", opts, func(ctx context.Context) (cibaRequest, error) {
+		_, err := c.getSession(ctx, nil)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.authReq == nil {
+			return cibaRequest{}, err
 		}
-		time.Sleep(5 * time.Second)
+		return *c.authReq, err
+	})
+}
+
+// pendingRequest returns the backchannel authentication request awaiting
+// the user, if any.
+func (c *KYCMatchUserClient) pendingRequest() (cibaRequest, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.authReq == nil {
+		return cibaRequest{}, false
 	}
+	return *c.authReq, true
+}
+
+// resume makes req, started elsewhere, the request awaiting the user.
+func (c *KYCMatchUserClient) resume(req *cibaRequest) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.RequiresConsent = req.consentURL != ""
+	c.consentURL = req.consentURL
+	c.authReq = req
 }
 
-func (c *KYCMatchUserClient) generateNewSession() (*types.Session, error) {
-	if c.settings.ClientID == "" || c.settings.ClientSecret == "" {
-		return nil, fmt.Errorf("[GlideClient] Client credentials are required to generate a new session")
+// abandon drops the request awaiting the user, if any.
+func (c *KYCMatchUserClient) abandon() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.authReq != nil {
+		c.authReq.close()
+		c.authReq = nil
 	}
+}
 
-	if c.authReqID == "" {
-		if err := c.StartSession(); err != nil {
+// generateNewSession redeems the pending backchannel authentication request
+// for a session, starting one first if needed; c.mu must be held. The
+// request is kept while the user has not answered yet or the poll failed
+// transiently.
+func (c *KYCMatchUserClient) generateNewSession(ctx context.Context) (*types.Session, error) {
+	if c.authReq == nil {
+		if err := c.startSession(ctx); err != nil {
 			return nil, err
 		}
 	}
 
-	if c.authReqID == "" {
+	if c.authReq.authReqID == "" {
+		c.authReq.close()
+		c.authReq = nil
 		return nil, fmt.Errorf("[GlideClient] Failed to start session")
 	}
 
-	data := url.Values{}
-	data.Set("grant_type", "urn:openid:params:grant-type:ciba")
-	data.Set("auth_req_id", c.authReqID)
-
-	resp, err := utils.FetchX(c.settings.Internal.AuthBaseURL+"/oauth2/token", utils.FetchXInput{
-		Method: "POST",
-		Headers: map[string]string{
-			"Content-Type":  "application/x-www-form-urlencoded",
-			"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(c.settings.ClientID+":"+c.settings.ClientSecret)),
-		},
-		Body: data.Encode(),
-	})
-
-	if err != nil {
-		c.authReqID = ""
-		return nil, fmt.Errorf("[GlideClient] FetchX failed: %w", err)
-	}
-
-	var body struct {
-		AccessToken string `json:"access_token"`
-		ExpiresIn   int64  `json:"expires_in"`
-		Scope       string `json:"scope"`
-	}
-	if err := resp.JSON(&body); err != nil {
-		return nil, fmt.Errorf("[GlideClient] Failed to parse response: %w", err)
+	session, err := redeemCIBA(ctx, c.settings, c.logger, "kyc-match", c.authReq)
+	if err != nil && cibaKept(ctx, err) {
+		return nil, err
 	}
-
-	return &types.Session{
-		AccessToken: body.AccessToken,
-		ExpiresAt:   time.Now().Unix() + body.ExpiresIn,
-		Scopes:      strings.Split(body.Scope, " "),
-	}, nil
+	c.authReq.close()
+	c.authReq = nil
+	return session, err
 }
 
-func (c *KYCMatchUserClient) reportKYCMatchMetric(wg *sync.WaitGroup, sessionId, metricName string, operator string) {
+func (c *KYCMatchUserClient) reportKYCMatchMetric(ctx context.Context, sessionId, metricName string, operator string) {
+	clientID, _ := utils.ClientID(ctx, c.settings)
 	metric := types.MetricInfo{
 		Operator:   operator,
 		Timestamp:  time.Now(),
 		SessionId:  sessionId,
 		MetricName: metricName,
 		Api:        "kyc-match",
-		ClientId:   c.settings.ClientID,
+		ClientId:   clientID,
 	}
-	wg.Add(1)
-	go func(m types.MetricInfo) {
-		defer wg.Done()
-		utils.ReportMetric(m)
-	}(metric)
+	c.metrics.Report(ctx, metric)
 }
 
 // Main client for KYC match operations
@@ -282,14 +368,26 @@ func NewKYCMatchClient(settings types.GlideSdkSettings) *KYCMatchClient {
 }
 
 func (c *KYCMatchClient) For(identifier types.UserIdentifier) (*KYCMatchUserClient, error) {
+	return c.ForWithContext(context.Background(), identifier)
+}
+
+// ForWithContext is like For but bound to ctx.
+func (c *KYCMatchClient) ForWithContext(ctx context.Context, identifier types.UserIdentifier) (*KYCMatchUserClient, error) {
 	client := NewKYCMatchUserClient(c.settings, identifier)
-	err := client.StartSession()
+	err := client.StartSessionWithContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return client, nil
 }
 
+// NewConsentHandler returns a handler running the consent workflow of c.
+func (c *KYCMatchClient) NewConsentHandler(opts ConsentOptions[*KYCMatchUserClient]) *ConsentHandler[*KYCMatchUserClient] {
+	return newConsentHandler(c.settings, "kyc-match", c.ForWithContext, func(identifier types.UserIdentifier) *KYCMatchUserClient {
+		return NewKYCMatchUserClient(c.settings, identifier)
+	}, opts)
+}
+
 func (c *KYCMatchClient) GetHello() string {
 	return "Hello"
 }
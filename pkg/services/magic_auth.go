@@ -1,16 +1,15 @@
 package services
 
 import (
-	"encoding/base64"
+	"context"
 	"encoding/json"
 	"fmt"
-	"net/url"
-	"strings"
-	"sync"
+	"log/slog"
 	"time"
 
 	"github.com/GlideApis/sdk-go/pkg/types"
 	"github.com/GlideApis/sdk-go/pkg/utils"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type MagicAuthStartResponse struct {
@@ -26,26 +25,44 @@ type MagicAuthCheckResponse struct {
 
 type MagicAuthClient struct {
 	settings types.GlideSdkSettings
-	session  *types.Session
+	logger   *slog.Logger
+	tracer   trace.Tracer
+	tokens   types.TokenSource
+	metrics  types.MetricsSink
 }
 
 func NewMagicAuthClient(settings types.GlideSdkSettings) *MagicAuthClient {
 	return &MagicAuthClient{
 		settings: settings,
+		logger:   utils.NewLogger(settings),
+		tracer:   utils.NewTracer(settings),
+		tokens:   tokenSource(settings),
+		metrics:  metricsSink(settings),
 	}
 }
 
 func (c *MagicAuthClient) StartAuth(props types.MagicAuthStartProps, conf types.ApiConfig) (*MagicAuthStartResponse, error) {
-	var wg sync.WaitGroup
+	return c.StartAuthWithContext(context.Background(), props, conf)
+}
+
+// StartAuthWithContext is like StartAuth but bound to ctx.
+func (c *MagicAuthClient) StartAuthWithContext(ctx context.Context, props types.MagicAuthStartProps, conf types.ApiConfig) (res *MagicAuthStartResponse, err error) {
+	ctx, span := utils.StartSpan(ctx, c.tracer, "magic-auth", "start")
+	defer func() { utils.EndSpan(span, err) }()
+	start := time.Now()
+	defer func() {
+		utils.LogOperation(ctx, c.logger, "magic-auth", "start", start, err)
+		emitFailure(ctx, c.settings, "magic-auth", "start", conf.SessionIdentifier, err)
+	}()
 	if c.settings.Internal.APIBaseURL == "" {
-		utils.Logger.Error("internal.apiBaseUrl is unset")
-		return nil, fmt.Errorf("[GlideClient] internal.apiBaseUrl is unset")
+		c.logger.Error("internal.apiBaseUrl is unset")
+		return nil, utils.ConfigError("internal.apiBaseUrl is unset")
 	}
 	if conf.SessionIdentifier != "" {
-		c.reportMagicAuthMetric(&wg, conf.SessionIdentifier, "Glide start", "")
+		c.reportMagicAuthMetric(ctx, conf.SessionIdentifier, "Glide start", "")
 	}
 
-	session, err := c.getSession(conf.Session)
+	session, err := c.getSession(ctx, conf.Session)
 	if err != nil {
 		return nil, err
 	}
@@ -73,8 +90,12 @@ func (c *MagicAuthClient) StartAuth(props types.MagicAuthStartProps, conf types.
 		return nil, err
 	}
 
-	resp, err := utils.FetchX(c.settings.Internal.APIBaseURL+"/magic-auth/verification/start", utils.FetchXInput{
-		Method: "POST",
+	resp, err := utils.FetchXWithContext(ctx, c.settings.Internal.APIBaseURL+"/magic-auth/verification/start", utils.FetchXInput{
+		Client:    c.settings.HTTPClient,
+		Logger:    c.logger,
+		Retry:     c.settings.Retry,
+		Collector: c.settings.Collector,
+		Method:    "POST",
 		Headers: map[string]string{
 			"Content-Type":  "application/json",
 			"Authorization": "Bearer " + session.AccessToken,
@@ -83,8 +104,9 @@ func (c *MagicAuthClient) StartAuth(props types.MagicAuthStartProps, conf types.
 	})
 
 	if err != nil {
-		utils.Logger.Error("FetchX failed for startAuth: %v", err)
-		return nil, fmt.Errorf("[GlideClient]: [magic-auth] FetchX failed for startAuth : %w", err)
+		err = utils.NewAPIError("magic-auth", "start", err, session)
+		invalidateRejectedToken(ctx, c.tokens, conf.Session, err, "magic-auth")
+		return nil, err
 	}
 
 	var result MagicAuthStartResponse
@@ -92,10 +114,14 @@ func (c *MagicAuthClient) StartAuth(props types.MagicAuthStartProps, conf types.
 		return nil, fmt.Errorf("[GlideClient] Failed to parse response: %w", err)
 	}
 
+	utils.SetSpanOperator(ctx, result.OperatorId)
+	emit(ctx, c.settings, types.Event{Type: types.EventAuthStarted, API: "magic-auth", Operation: "start", SessionID: conf.SessionIdentifier, Operator: result.OperatorId})
+	if result.OperatorId != "" {
+		emit(ctx, c.settings, types.Event{Type: types.EventOperatorResolved, API: "magic-auth", Operation: "start", SessionID: conf.SessionIdentifier, Operator: result.OperatorId})
+	}
 	if conf.SessionIdentifier != "" && result.OperatorId != "" {
-		c.reportMagicAuthMetric(&wg, conf.SessionIdentifier, "Glide verificationStartRes", result.OperatorId)
+		c.reportMagicAuthMetric(ctx, conf.SessionIdentifier, "Glide verificationStartRes", result.OperatorId)
 	}
-	wg.Wait()
 	return &result, nil
 }
 
@@ -104,12 +130,23 @@ type MagicAuthVerifyRes struct {
 }
 
 func (c *MagicAuthClient) VerifyAuth(props types.MagicAuthVerifyProps, conf types.ApiConfig) (*MagicAuthVerifyRes, error) {
-	var wg sync.WaitGroup
+	return c.VerifyAuthWithContext(context.Background(), props, conf)
+}
+
+// VerifyAuthWithContext is like VerifyAuth but bound to ctx.
+func (c *MagicAuthClient) VerifyAuthWithContext(ctx context.Context, props types.MagicAuthVerifyProps, conf types.ApiConfig) (res *MagicAuthVerifyRes, err error) {
+	ctx, span := utils.StartSpan(ctx, c.tracer, "magic-auth", "verify")
+	defer func() { utils.EndSpan(span, err) }()
+	start := time.Now()
+	defer func() {
+		utils.LogOperation(ctx, c.logger, "magic-auth", "verify", start, err)
+		emitFailure(ctx, c.settings, "magic-auth", "verify", conf.SessionIdentifier, err)
+	}()
 	if c.settings.Internal.APIBaseURL == "" {
-		return nil, fmt.Errorf("[GlideClient] internal.apiBaseUrl is unset")
+		return nil, utils.ConfigError("internal.apiBaseUrl is unset")
 	}
 
-	session, err := c.getSession(conf.Session)
+	session, err := c.getSession(ctx, conf.Session)
 	if err != nil {
 		return nil, err
 	}
@@ -134,8 +171,12 @@ func (c *MagicAuthClient) VerifyAuth(props types.MagicAuthVerifyProps, conf type
 		return nil, err
 	}
 
-	resp, err := utils.FetchX(c.settings.Internal.APIBaseURL+"/magic-auth/verification/check", utils.FetchXInput{
-		Method: "POST",
+	resp, err := utils.FetchXWithContext(ctx, c.settings.Internal.APIBaseURL+"/magic-auth/verification/check", utils.FetchXInput{
+		Client:    c.settings.HTTPClient,
+		Logger:    c.logger,
+		Retry:     c.settings.Retry,
+		Collector: c.settings.Collector,
+		Method:    "POST",
 		Headers: map[string]string{
 			"Content-Type":  "application/json",
 			"Authorization": "Bearer " + session.AccessToken,
@@ -144,98 +185,47 @@ func (c *MagicAuthClient) VerifyAuth(props types.MagicAuthVerifyProps, conf type
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("[GlideClient]: [magic-auth] FetchX failed for VerifyAuth : %w", err)
+		err = utils.NewAPIError("magic-auth", "verify", err, session)
+		invalidateRejectedToken(ctx, c.tokens, conf.Session, err, "magic-auth")
+		return nil, err
 	}
 
 	var result MagicAuthVerifyRes
 	if err := resp.JSON(&result); err != nil {
 		return nil, fmt.Errorf("[GlideClient] Failed to parse response in VerifyAuth: %w", err)
 	}
+	emit(ctx, c.settings, types.Event{Type: verificationResult(result.Verified), API: "magic-auth", Operation: "verify", SessionID: conf.SessionIdentifier})
 
 	if conf.SessionIdentifier != "" {
-		c.reportMagicAuthMetric(&wg, conf.SessionIdentifier, "Glide success", "")
+		c.reportMagicAuthMetric(ctx, conf.SessionIdentifier, "Glide success", "")
 		if result.Verified {
-			c.reportMagicAuthMetric(&wg, conf.SessionIdentifier, "Glide verified", "")
+			c.reportMagicAuthMetric(ctx, conf.SessionIdentifier, "Glide verified", "")
 		} else if !result.Verified {
-			c.reportMagicAuthMetric(&wg, conf.SessionIdentifier, "Glide unverified", "")
+			c.reportMagicAuthMetric(ctx, conf.SessionIdentifier, "Glide unverified", "")
 		}
 	}
-	wg.Wait()
 	return &result, nil
 }
 
-func (c *MagicAuthClient) getSession(confSession *types.Session) (*types.Session, error) {
+func (c *MagicAuthClient) getSession(ctx context.Context, confSession *types.Session) (*types.Session, error) {
 	if confSession != nil {
 		return confSession, nil
 	}
-
-	if c.session != nil && c.session.ExpiresAt > time.Now().Add(time.Minute).Unix() && contains(c.session.Scopes, "magic-auth") {
-		utils.Logger.Debug("Using cached session")
-		return c.session, nil
-	}
-
-	session, err := c.generateNewSession()
-	if err != nil {
-		return nil, err
-	}
-
-	c.session = session
-	return session, nil
-}
-
-func (c *MagicAuthClient) generateNewSession() (*types.Session, error) {
-	if c.settings.ClientID == "" || c.settings.ClientSecret == "" {
-		return nil, fmt.Errorf("[GlideClient] Client credentials are required to generate a new session")
-	}
-
-	data := url.Values{}
-	data.Set("grant_type", "client_credentials")
-	data.Set("scope", "magic-auth")
-
-	resp, err := utils.FetchX(c.settings.Internal.AuthBaseURL+"/oauth2/token", utils.FetchXInput{
-		Method: "POST",
-		Headers: map[string]string{
-			"Content-Type":  "application/x-www-form-urlencoded",
-			"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(c.settings.ClientID+":"+c.settings.ClientSecret)),
-		},
-		Body: data.Encode(),
-	})
-
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate new session: %w", err)
-	}
-
-	var body struct {
-		AccessToken string `json:"access_token"`
-		ExpiresIn   int64  `json:"expires_in"`
-		Scope       string `json:"scope"`
-	}
-	if err := resp.JSON(&body); err != nil {
-		return nil, err
-	}
-
-	return &types.Session{
-		AccessToken: body.AccessToken,
-		ExpiresAt:   time.Now().Unix() + body.ExpiresIn,
-		Scopes:      strings.Split(body.Scope, " "),
-	}, nil
+	return c.tokens.Token(ctx, "magic-auth")
 }
 
-func (c *MagicAuthClient) reportMagicAuthMetric(wg *sync.WaitGroup, sessionId, metricName string, operator string) {
-	utils.Logger.Debug("reportMagicAuthMetric: %s", metricName)
+func (c *MagicAuthClient) reportMagicAuthMetric(ctx context.Context, sessionId, metricName string, operator string) {
+	c.logger.Debug("Reporting metric", "api", "magic-auth", "metric", metricName)
+	clientID, _ := utils.ClientID(ctx, c.settings)
 	metric := types.MetricInfo{
 		Operator:   operator,
 		Timestamp:  time.Now(),
 		SessionId:  sessionId,
 		MetricName: metricName,
 		Api:        "magic-auth",
-		ClientId:   c.settings.ClientID,
+		ClientId:   clientID,
 	}
-	wg.Add(1)
-	go func(m types.MetricInfo) {
-		defer wg.Done()
-		utils.ReportMetric(m)
-	}(metric)
+	c.metrics.Report(ctx, metric)
 }
 
 func (c *MagicAuthClient) GetHello() string {
@@ -243,16 +233,27 @@ func (c *MagicAuthClient) GetHello() string {
 }
 
 func (c *MagicAuthClient) StartServerAuth(props types.MagicAuthStartProps, conf types.ApiConfig) (*types.MagicAuthStartServerAuthResponse, error) {
-	var wg sync.WaitGroup
+	return c.StartServerAuthWithContext(context.Background(), props, conf)
+}
+
+// StartServerAuthWithContext is like StartServerAuth but bound to ctx.
+func (c *MagicAuthClient) StartServerAuthWithContext(ctx context.Context, props types.MagicAuthStartProps, conf types.ApiConfig) (res *types.MagicAuthStartServerAuthResponse, err error) {
+	ctx, span := utils.StartSpan(ctx, c.tracer, "magic-auth", "start-server-auth")
+	defer func() { utils.EndSpan(span, err) }()
+	start := time.Now()
+	defer func() {
+		utils.LogOperation(ctx, c.logger, "magic-auth", "start-server-auth", start, err)
+		emitFailure(ctx, c.settings, "magic-auth", "start-server-auth", conf.SessionIdentifier, err)
+	}()
 	if c.settings.Internal.APIBaseURL == "" {
-		utils.Logger.Error("internal.apiBaseUrl is unset")
-		return nil, fmt.Errorf("[GlideClient] internal.apiBaseUrl is unset")
+		c.logger.Error("internal.apiBaseUrl is unset")
+		return nil, utils.ConfigError("internal.apiBaseUrl is unset")
 	}
 	if conf.SessionIdentifier != "" {
-		c.reportMagicAuthMetric(&wg, conf.SessionIdentifier, "Glide start server auth", "")
+		c.reportMagicAuthMetric(ctx, conf.SessionIdentifier, "Glide start server auth", "")
 	}
 
-	session, err := c.getSession(conf.Session)
+	session, err := c.getSession(ctx, conf.Session)
 	if err != nil {
 		return nil, err
 	}
@@ -287,8 +288,12 @@ func (c *MagicAuthClient) StartServerAuth(props types.MagicAuthStartProps, conf
 		return nil, err
 	}
 
-	resp, err := utils.FetchX(c.settings.Internal.APIBaseURL+"/magic-auth/verification/start-server-auth", utils.FetchXInput{
-		Method: "POST",
+	resp, err := utils.FetchXWithContext(ctx, c.settings.Internal.APIBaseURL+"/magic-auth/verification/start-server-auth", utils.FetchXInput{
+		Client:    c.settings.HTTPClient,
+		Logger:    c.logger,
+		Retry:     c.settings.Retry,
+		Collector: c.settings.Collector,
+		Method:    "POST",
 		Headers: map[string]string{
 			"Content-Type":  "application/json",
 			"Authorization": "Bearer " + session.AccessToken,
@@ -297,36 +302,52 @@ func (c *MagicAuthClient) StartServerAuth(props types.MagicAuthStartProps, conf
 	})
 
 	if err != nil {
-		utils.Logger.Error("FetchX failed for startServerAuth: %v", err)
-		return nil, fmt.Errorf("[GlideClient]: [magic-auth] FetchX failed for startServerAuth : %w", err)
+		err = utils.NewAPIError("magic-auth", "start-server-auth", err, session)
+		invalidateRejectedToken(ctx, c.tokens, conf.Session, err, "magic-auth")
+		return nil, err
 	}
 
 	var result types.MagicAuthStartServerAuthResponse
 	if err := resp.JSON(&result); err != nil {
 		return nil, fmt.Errorf("[GlideClient] Failed to parse response: %w", err)
 	}
+	emit(ctx, c.settings, types.Event{Type: types.EventAuthStarted, API: "magic-auth", Operation: "start-server-auth", SessionID: conf.SessionIdentifier})
 
 	if conf.SessionIdentifier != "" {
-		c.reportMagicAuthMetric(&wg, conf.SessionIdentifier, "Glide serverAuthStartRes", "")
+		c.reportMagicAuthMetric(ctx, conf.SessionIdentifier, "Glide serverAuthStartRes", "")
 	}
-	wg.Wait()
 	return &result, nil
 }
 
 func (c *MagicAuthClient) CheckServerAuth(sessionID string, conf types.ApiConfig) (*types.MagicAuthCheckServerAuthResponse, error) {
-	var wg sync.WaitGroup
+	return c.CheckServerAuthWithContext(context.Background(), sessionID, conf)
+}
+
+// CheckServerAuthWithContext is like CheckServerAuth but bound to ctx.
+func (c *MagicAuthClient) CheckServerAuthWithContext(ctx context.Context, sessionID string, conf types.ApiConfig) (res *types.MagicAuthCheckServerAuthResponse, err error) {
+	ctx, span := utils.StartSpan(ctx, c.tracer, "magic-auth", "check-server-auth")
+	defer func() { utils.EndSpan(span, err) }()
+	start := time.Now()
+	defer func() {
+		utils.LogOperation(ctx, c.logger, "magic-auth", "check-server-auth", start, err)
+		emitFailure(ctx, c.settings, "magic-auth", "check-server-auth", conf.SessionIdentifier, err)
+	}()
 	if c.settings.Internal.APIBaseURL == "" {
-		return nil, fmt.Errorf("[GlideClient] internal.apiBaseUrl is unset")
+		return nil, utils.ConfigError("internal.apiBaseUrl is unset")
 	}
 
-	session, err := c.getSession(conf.Session)
+	session, err := c.getSession(ctx, conf.Session)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := utils.FetchX(fmt.Sprintf("%s/magic-auth/verification/check-server-auth?sessionId=%s",
+	resp, err := utils.FetchXWithContext(ctx, fmt.Sprintf("%s/magic-auth/verification/check-server-auth?sessionId=%s",
 		c.settings.Internal.APIBaseURL, sessionID), utils.FetchXInput{
-		Method: "GET",
+		Client:    c.settings.HTTPClient,
+		Logger:    c.logger,
+		Retry:     c.settings.Retry,
+		Collector: c.settings.Collector,
+		Method:    "GET",
 		Headers: map[string]string{
 			"Content-Type":  "application/json",
 			"Authorization": "Bearer " + session.AccessToken,
@@ -334,22 +355,24 @@ func (c *MagicAuthClient) CheckServerAuth(sessionID string, conf types.ApiConfig
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("[GlideClient]: [magic-auth] FetchX failed for CheckServerAuth : %w", err)
+		err = utils.NewAPIError("magic-auth", "check-server-auth", err, session)
+		invalidateRejectedToken(ctx, c.tokens, conf.Session, err, "magic-auth")
+		return nil, err
 	}
 
 	var result types.MagicAuthCheckServerAuthResponse
 	if err := resp.JSON(&result); err != nil {
 		return nil, fmt.Errorf("[GlideClient] Failed to parse response in CheckServerAuth: %w", err)
 	}
+	emit(ctx, c.settings, types.Event{Type: verificationResult(result.Verified), API: "magic-auth", Operation: "check-server-auth", SessionID: conf.SessionIdentifier})
 
 	if conf.SessionIdentifier != "" {
-		c.reportMagicAuthMetric(&wg, conf.SessionIdentifier, "Glide serverAuthCheck", "")
+		c.reportMagicAuthMetric(ctx, conf.SessionIdentifier, "Glide serverAuthCheck", "")
 		if result.Verified {
-			c.reportMagicAuthMetric(&wg, conf.SessionIdentifier, "Glide serverAuthVerified", "")
+			c.reportMagicAuthMetric(ctx, conf.SessionIdentifier, "Glide serverAuthVerified", "")
 		} else {
-			c.reportMagicAuthMetric(&wg, conf.SessionIdentifier, "Glide serverAuthUnverified", "")
+			c.reportMagicAuthMetric(ctx, conf.SessionIdentifier, "Glide serverAuthUnverified", "")
 		}
 	}
-	wg.Wait()
 	return &result, nil
 }
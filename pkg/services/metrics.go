@@ -0,0 +1,15 @@
+package services
+
+import (
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/GlideApis/sdk-go/pkg/utils"
+)
+
+// metricsSink returns the sink configured in settings, or one that discards
+// metrics when none is set.
+func metricsSink(settings types.GlideSdkSettings) types.MetricsSink {
+	if settings.Metrics != nil {
+		return settings.Metrics
+	}
+	return utils.NoopMetricsSink{}
+}
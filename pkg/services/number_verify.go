@@ -1,105 +1,181 @@
 package services
 
 import (
-	"encoding/base64"
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"log/slog"
 	"net/url"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/GlideApis/sdk-go/pkg/types"
 	"github.com/GlideApis/sdk-go/pkg/utils"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type NumberVerifyUserClient struct {
 	settings    types.GlideSdkSettings
+	logger      *slog.Logger
+	tracer      trace.Tracer
+	metrics     types.MetricsSink
+	verifier    types.IDTokenVerifier
 	session     *types.Session
 	code        string
 	phoneNumber *string
+	authRequest *types.NumberVerifyAuthRequest
+	state       string
 }
 
 func NewNumberVerifyUserClient(settings types.GlideSdkSettings, params types.NumberVerifyClientForParams) *NumberVerifyUserClient {
 	return &NumberVerifyUserClient{
 		settings:    settings,
+		logger:      utils.NewLogger(settings),
+		tracer:      utils.NewTracer(settings),
+		metrics:     metricsSink(settings),
+		verifier:    idTokenVerifier(settings),
 		code:        params.Code,
 		phoneNumber: params.PhoneNumber,
+		authRequest: params.AuthRequest,
+		state:       params.State,
 	}
 }
 
 func (c *NumberVerifyUserClient) StartSession() error {
+	return c.StartSessionWithContext(context.Background())
+}
+
+// StartSessionWithContext is like StartSession but bound to ctx.
+func (c *NumberVerifyUserClient) StartSessionWithContext(ctx context.Context) (err error) {
+	ctx, span := utils.StartSpan(ctx, c.tracer, "number-verify", "token")
+	defer func() { utils.EndSpan(span, err) }()
+	start := time.Now()
+	defer func() {
+		utils.LogOperation(ctx, c.logger, "number-verify", "token", start, err)
+		emitFailure(ctx, c.settings, "number-verify", "token", "", err)
+	}()
 	if c.settings.Internal.AuthBaseURL == "" {
-		utils.Logger.Error("internal.authBaseUrl is unset")
-		return errors.New("[GlideClient] internal.authBaseUrl is unset")
-	}
-	if c.settings.ClientID == "" || c.settings.ClientSecret == "" {
-		utils.Logger.Error("Client credentials are required to generate a new session")
-		return errors.New("[GlideClient] Client credentials are required to generate a new session")
+		c.logger.Error("internal.authBaseUrl is unset")
+		return utils.ConfigError("internal.authBaseUrl is unset")
 	}
 	if c.code == "" {
-		utils.Logger.Error("Code is required to start a session")
-		return errors.New("[GlideClient] Code is required to start a session")
+		c.logger.Error("Code is required to start a session")
+		return utils.InvalidRequestError("Code is required to start a session")
+	}
+	if c.authRequest != nil && !utils.SecureCompare(c.state, c.authRequest.State) {
+		c.logger.Error("State does not match the authorization request")
+		return utils.InvalidRequestError("State does not match the authorization request")
 	}
 	data := url.Values{}
 	data.Set("grant_type", "authorization_code")
 	data.Set("code", c.code)
-	resp, err := utils.FetchX(c.settings.Internal.AuthBaseURL+"/oauth2/token", utils.FetchXInput{
-		Method: "POST",
-		Headers: map[string]string{
-			"Content-Type":  "application/x-www-form-urlencoded",
-			"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(c.settings.ClientID+":"+c.settings.ClientSecret)),
-		},
-		Body: data.Encode(),
+	if c.settings.RedirectURI != "" {
+		data.Set("redirect_uri", c.settings.RedirectURI)
+	}
+	if c.authRequest != nil && c.authRequest.CodeVerifier != "" {
+		data.Set("code_verifier", c.authRequest.CodeVerifier)
+	}
+	headers := map[string]string{"Content-Type": "application/x-www-form-urlencoded"}
+	if err := utils.AuthenticateClient(ctx, c.settings, headers, data); err != nil {
+		c.logger.Error("Failed to authenticate token request", "api", "number-verify", "error", err)
+		return err
+	}
+	resp, err := utils.FetchXWithContext(ctx, utils.EndpointsFor(ctx, c.settings).TokenEndpoint, utils.FetchXInput{
+		Client:    c.settings.HTTPClient,
+		Logger:    c.logger,
+		Retry:     c.settings.Retry,
+		Collector: c.settings.Collector,
+		Method:    "POST",
+		Headers:   headers,
+		Body:      data.Encode(),
 	})
 	if err != nil {
-		utils.Logger.Error("Failed to generate new session: %v", err)
-		return fmt.Errorf("failed to generate new session: %w", err)
+		c.logger.Error("Failed to generate new session", "api", "number-verify", "error", err)
+		return utils.NewAPIError("number-verify", "token", err, nil)
 	}
 	var body struct {
 		AccessToken string `json:"access_token"`
 		ExpiresIn   int64  `json:"expires_in"`
 		Scope       string `json:"scope"`
+		IDToken     string `json:"id_token"`
 	}
 
 	if err := resp.JSON(&body); err != nil {
-		utils.Logger.Error("Failed to parse response: %v", err)
-		fmt.Errorf("[GlideClient] Failed to parse response: %w", err)
-		return nil
+		c.logger.Error("Failed to parse response", "api", "number-verify", "error", err)
+		return fmt.Errorf("[GlideClient] Failed to parse response: %w", err)
 	}
-
-	c.session = &types.Session{
+	session := &types.Session{
 		AccessToken: body.AccessToken,
 		ExpiresAt:   time.Now().Unix() + body.ExpiresIn,
 		Scopes:      strings.Split(body.Scope, " "),
+		IDToken:     body.IDToken,
+	}
+	if c.authRequest != nil {
+		if body.IDToken == "" {
+			err := utils.InvalidTokenError("id_token is missing from the token response")
+			c.logger.Error("Invalid id_token", "api", "number-verify", "error", err)
+			return err
+		}
+		if _, err := c.verifier.Verify(ctx, body.IDToken, c.authRequest.Nonce); err != nil {
+			c.logger.Error("Invalid id_token", "api", "number-verify", "error", err)
+			return err
+		}
+	}
+	c.session = session
+	if operator, _ := utils.GetOperator(c.session); operator != "" {
+		emit(ctx, c.settings, types.Event{Type: types.EventOperatorResolved, API: "number-verify", Operation: "token", Operator: operator})
 	}
 	return nil
 }
 
+// IDTokenClaims verifies the ID token returned with the session's access
+// token, including the nonce when the session was started for an
+// AuthRequest, and returns its claims.
+func (c *NumberVerifyUserClient) IDTokenClaims(ctx context.Context) (*types.IDTokenClaims, error) {
+	if c.session == nil || c.session.IDToken == "" {
+		return nil, utils.InvalidRequestError("Session has no ID token")
+	}
+	nonce := ""
+	if c.authRequest != nil {
+		nonce = c.authRequest.Nonce
+	}
+	return c.verifier.Verify(ctx, c.session.IDToken, nonce)
+}
+
 func (c *NumberVerifyUserClient) GetOperator() (string, error) {
 	return utils.GetOperator(c.session)
 }
 
 func (c *NumberVerifyUserClient) VerifyNumber(number *string, conf types.ApiConfig) (*types.NumberVerifyResponse, error) {
-	var wg sync.WaitGroup
+	return c.VerifyNumberWithContext(context.Background(), number, conf)
+}
+
+// VerifyNumberWithContext is like VerifyNumber but bound to ctx.
+func (c *NumberVerifyUserClient) VerifyNumberWithContext(ctx context.Context, number *string, conf types.ApiConfig) (res *types.NumberVerifyResponse, err error) {
+	ctx, span := utils.StartSpan(ctx, c.tracer, "number-verify", "verify")
+	defer func() { utils.EndSpan(span, err) }()
+	start := time.Now()
+	defer func() {
+		utils.LogOperation(ctx, c.logger, "number-verify", "verify", start, err)
+		emitFailure(ctx, c.settings, "number-verify", "verify", conf.SessionIdentifier, err)
+	}()
 	if conf.SessionIdentifier != "" {
 		operator, err := utils.GetOperator(c.session)
 		if err != nil {
-			utils.Logger.Error("Cannot report metric since failed to get operator: %v", err)
-			fmt.Errorf("cannot report metric since failed to get operator: %w", err)
+			c.logger.Error("Cannot report metric since failed to get operator", "api", "number-verify", "error", err)
 		}
-		c.reportNumberVerifyMetric(&wg, conf.SessionIdentifier, "Glide numberVerify start function", operator)
+		c.reportNumberVerifyMetric(ctx, conf.SessionIdentifier, "Glide numberVerify start function", operator)
 	}
 	if c.session == nil {
-		utils.Logger.Error("Session is required to verify a number")
-		return nil, errors.New("[GlideClient] Session is required to verify a number")
+		c.logger.Error("Session is required to verify a number")
+		return nil, utils.InvalidRequestError("Session is required to verify a number")
 	}
+	utils.SetSpanSessionOperator(ctx, c.session)
 
 	if c.settings.Internal.APIBaseURL == "" {
-		utils.Logger.Error("internal.apiBaseUrl is unset")
-		return nil, errors.New("[GlideClient] internal.apiBaseUrl is unset")
+		c.logger.Error("internal.apiBaseUrl is unset")
+		return nil, utils.ConfigError("internal.apiBaseUrl is unset")
 	}
 
 	var phoneNumber string
@@ -108,17 +184,22 @@ func (c *NumberVerifyUserClient) VerifyNumber(number *string, conf types.ApiConf
 	} else if c.phoneNumber != nil {
 		phoneNumber = *c.phoneNumber
 	} else {
-		return nil, errors.New("[GlideClient] Phone number is required to verify a number")
+		return nil, utils.InvalidRequestError("Phone number is required to verify a number")
 	}
 
 	body, err := json.Marshal(map[string]string{"phoneNumber": utils.FormatPhoneNumber(phoneNumber)})
 	if err != nil {
-		utils.Logger.Error("Failed to marshal payload in number verify: %v", err)
+		c.logger.Error("Failed to marshal payload in number verify", "error", err)
 		return nil, fmt.Errorf("[GlideClient] failed to marshal payload in number verify: %w", err)
 	}
 
-	resp, err := utils.FetchX(c.settings.Internal.APIBaseURL+"/number-verification/verify", utils.FetchXInput{
-		Method: "POST",
+	resp, err := utils.FetchXWithContext(ctx, c.settings.Internal.APIBaseURL+"/number-verification/verify", utils.FetchXInput{
+		Client:     c.settings.HTTPClient,
+		Logger:     c.logger,
+		Retry:      c.settings.Retry,
+		Collector:  c.settings.Collector,
+		Idempotent: true,
+		Method:     "POST",
 		Headers: map[string]string{
 			"Content-Type":  "application/json",
 			"Authorization": "Bearer " + c.session.AccessToken,
@@ -127,96 +208,136 @@ func (c *NumberVerifyUserClient) VerifyNumber(number *string, conf types.ApiConf
 	})
 
 	if err != nil {
-		utils.Logger.Error("Failed to verify number: %v", err)
-		return nil, fmt.Errorf("failed to verify number: %w", err)
+		c.logger.Error("Failed to verify number", "api", "number-verify", "error", err)
+		return nil, utils.NewAPIError("number-verify", "verify", err, c.session)
 	}
 
 	var result types.NumberVerifyResponse
 	if err := resp.JSON(&result); err != nil {
-		utils.Logger.Error("Failed to parse response: %v", err)
+		c.logger.Error("Failed to parse response", "api", "number-verify", "error", err)
 		return nil, fmt.Errorf("[GlideClient] Failed to parse response: %w", err)
 	}
+	operator, _ := utils.GetOperator(c.session)
+	emit(ctx, c.settings, types.Event{Type: verificationResult(result.DevicePhoneNumberVerified), API: "number-verify", Operation: "verify", SessionID: conf.SessionIdentifier, Operator: operator})
 	// Metric reporting for success/failure
 	if conf.SessionIdentifier != "" {
-		c.reportNumberVerifyMetric(&wg, conf.SessionIdentifier, "Glide success", "")
+		c.reportNumberVerifyMetric(ctx, conf.SessionIdentifier, "Glide success", "")
 		if result.DevicePhoneNumberVerified {
-			c.reportNumberVerifyMetric(&wg, conf.SessionIdentifier, "Glide verified", "")
+			c.reportNumberVerifyMetric(ctx, conf.SessionIdentifier, "Glide verified", "")
 		} else {
-			c.reportNumberVerifyMetric(&wg, conf.SessionIdentifier, "Glide unverified", "")
+			c.reportNumberVerifyMetric(ctx, conf.SessionIdentifier, "Glide unverified", "")
 		}
 	}
-	wg.Wait()
 	return &result, nil
 }
 
 type NumberVerifyClient struct {
 	settings types.GlideSdkSettings
+	logger   *slog.Logger
 }
 
 func NewNumberVerifyClient(settings types.GlideSdkSettings) *NumberVerifyClient {
-	return &NumberVerifyClient{settings: settings}
+	return &NumberVerifyClient{settings: settings, logger: utils.NewLogger(settings)}
 }
 
+// GetAuthURL returns the URL to redirect the user to. Use GetAuthRequest
+// instead to get PKCE and state and nonce verification.
 func (c *NumberVerifyClient) GetAuthURL(opts ...types.NumberVerifyAuthUrlInput) (string, error) {
+	req, err := c.authRequest(context.Background(), false, opts...)
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+// GetAuthRequest returns the URL to redirect the user to along with the
+// state, nonce and PKCE code verifier to check the redirect against. Pass
+// the result to For as NumberVerifyClientForParams.AuthRequest.
+func (c *NumberVerifyClient) GetAuthRequest(opts ...types.NumberVerifyAuthUrlInput) (*types.NumberVerifyAuthRequest, error) {
+	return c.GetAuthRequestWithContext(context.Background(), opts...)
+}
+
+// GetAuthRequestWithContext is GetAuthRequest with a context bounding the
+// lookup of the authorization endpoint.
+func (c *NumberVerifyClient) GetAuthRequestWithContext(ctx context.Context, opts ...types.NumberVerifyAuthUrlInput) (*types.NumberVerifyAuthRequest, error) {
+	return c.authRequest(ctx, len(opts) == 0 || !opts[0].DisablePKCE, opts...)
+}
+
+func (c *NumberVerifyClient) authRequest(ctx context.Context, pkce bool, opts ...types.NumberVerifyAuthUrlInput) (*types.NumberVerifyAuthRequest, error) {
 	if c.settings.Internal.AuthBaseURL == "" {
-		utils.Logger.Error("internal.authBaseUrl is unset")
-		return "", errors.New("[GlideClient] internal.authBaseUrl is unset")
+		c.logger.Error("internal.authBaseUrl is unset")
+		return nil, utils.ConfigError("internal.authBaseUrl is unset")
 	}
-	if c.settings.ClientID == "" {
-		utils.Logger.Error("Client id is required to generate an auth url")
-		return "", errors.New("[GlideClient] Client id is required to generate an auth url")
+	clientID, err := utils.ClientID(ctx, c.settings)
+	if err != nil {
+		c.logger.Error("Client id is required to generate an auth url", "error", err)
+		return nil, utils.ConfigError("Client id is required to generate an auth url")
+	}
+	req := &types.NumberVerifyAuthRequest{Nonce: uuid.New().String()}
+	if len(opts) > 0 {
+		req.PhoneNumber = opts[0].PhoneNumber
 	}
-	var state string
 	if len(opts) > 0 && opts[0].State != nil {
-		state = *opts[0].State
+		req.State = *opts[0].State
 	} else {
-		state = uuid.New().String()
+		req.State = uuid.New().String()
 	}
-	nonce := uuid.New().String()
 	params := url.Values{}
-	params.Set("client_id", c.settings.ClientID)
+	params.Set("client_id", clientID)
 	params.Set("response_type", "code")
 	if c.settings.RedirectURI != "" {
 		params.Set("redirect_uri", c.settings.RedirectURI)
 	}
 	params.Set("scope", "openid")
 	params.Set("purpose", "dpv:FraudPreventionAndDetection:number-verification")
-	params.Set("state", state)
-	params.Set("nonce", nonce)
+	params.Set("state", req.State)
+	params.Set("nonce", req.Nonce)
 	params.Set("max_age", "0")
+	if pkce {
+		verifier, err := utils.NewCodeVerifier()
+		if err != nil {
+			return nil, fmt.Errorf("[GlideClient] Failed to generate code verifier: %w", err)
+		}
+		req.CodeVerifier = verifier
+		params.Set("code_challenge", utils.CodeChallengeS256(verifier))
+		params.Set("code_challenge_method", "S256")
+	}
 	if len(opts) > 0 && opts[0].UseDevNumber != "" {
 		params.Set("login_hint", "tel:"+opts[0].UseDevNumber)
 	}
 	if len(opts) > 0 && opts[0].PrintCode {
 		params.Set("dev_print", "true")
 	}
-	return c.settings.Internal.AuthBaseURL + "/oauth2/auth?" + params.Encode(), nil
+	req.URL = utils.EndpointsFor(ctx, c.settings).AuthorizationEndpoint + "?" + params.Encode()
+	return req, nil
 }
 
 func (c *NumberVerifyClient) For(params types.NumberVerifyClientForParams) (*NumberVerifyUserClient, error) {
+	return c.ForWithContext(context.Background(), params)
+}
+
+// ForWithContext is like For but bound to ctx.
+func (c *NumberVerifyClient) ForWithContext(ctx context.Context, params types.NumberVerifyClientForParams) (*NumberVerifyUserClient, error) {
 	client := NewNumberVerifyUserClient(c.settings, params)
-	err := client.StartSession()
+	err := client.StartSessionWithContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return client, nil
 }
 
-func (c *NumberVerifyUserClient) reportNumberVerifyMetric(wg *sync.WaitGroup, sessionId, metricName string, operator string) {
-	utils.Logger.Debug("reportNumberVerifyMetric: %s", metricName)
+func (c *NumberVerifyUserClient) reportNumberVerifyMetric(ctx context.Context, sessionId, metricName string, operator string) {
+	c.logger.Debug("Reporting metric", "api", "number-verify", "metric", metricName)
+	clientID, _ := utils.ClientID(ctx, c.settings)
 	metric := types.MetricInfo{
 		Operator:   operator,
 		Timestamp:  time.Now(),
 		SessionId:  sessionId,
 		MetricName: metricName,
 		Api:        "number-verify",
-		ClientId:   c.settings.ClientID,
+		ClientId:   clientID,
 	}
-	wg.Add(1)
-	go func(m types.MetricInfo) {
-		defer wg.Done()
-		utils.ReportMetric(m)
-	}(metric)
+	c.metrics.Report(ctx, metric)
 }
 
 func (c *NumberVerifyClient) GetHello() string {
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/GlideApis/sdk-go/pkg/utils"
+)
+
+// NumberVerifyCallbackResult is the outcome of a successful callback.
+type NumberVerifyCallbackResult struct {
+	AuthRequest *types.NumberVerifyAuthRequest
+	Client      *NumberVerifyUserClient
+	Result      *types.NumberVerifyResponse
+}
+
+// NumberVerifyCallbackOptions configures a NumberVerifyCallbackHandler.
+type NumberVerifyCallbackOptions struct {
+	// Store keeps pending requests between AuthURL and the callback. It
+	// defaults to an in-memory store, which only works when the callback
+	// reaches the same instance.
+	Store types.AuthRequestStore
+	// OnResult writes the response for a verified callback. By default the
+	// verification result is written as JSON.
+	OnResult func(w http.ResponseWriter, r *http.Request, result *NumberVerifyCallbackResult)
+	// OnError writes the response for a failed callback. By default only
+	// the status text is written, with status 400 for invalid or denied
+	// requests and 502 otherwise; the error itself is logged.
+	OnError func(w http.ResponseWriter, r *http.Request, err error)
+	// Config is passed to VerifyNumber, e.g. to set a session identifier.
+	Config types.ApiConfig
+}
+
+// NumberVerifyCallbackHandler serves the redirect URI of the Number Verify
+// authorization code flow. It checks the state against the stored request,
+// exchanges the code, verifies the request's phone number and hands the
+// result to OnResult.
+type NumberVerifyCallbackHandler struct {
+	client *NumberVerifyClient
+	opts   NumberVerifyCallbackOptions
+}
+
+// NewCallbackHandler returns a handler for the redirect URI of c.
+func (c *NumberVerifyClient) NewCallbackHandler(opts NumberVerifyCallbackOptions) *NumberVerifyCallbackHandler {
+	if opts.Store == nil {
+		opts.Store = utils.NewMemoryAuthRequestStore(0)
+	}
+	if opts.OnResult == nil {
+		opts.OnResult = writeCallbackResult
+	}
+	if opts.OnError == nil {
+		opts.OnError = writeCallbackError
+	}
+	return &NumberVerifyCallbackHandler{client: c, opts: opts}
+}
+
+// AuthURL builds an authorization request, stores it and returns the URL to
+// redirect the user to. Set the number to verify in opts' PhoneNumber.
+func (h *NumberVerifyCallbackHandler) AuthURL(ctx context.Context, opts ...types.NumberVerifyAuthUrlInput) (string, error) {
+	req, err := h.client.GetAuthRequestWithContext(ctx, opts...)
+	if err != nil {
+		return "", err
+	}
+	if err := h.opts.Store.Put(ctx, req); err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+func (h *NumberVerifyCallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	result, err := h.handle(r)
+	if err != nil {
+		h.client.logger.Error("Number verify callback failed", "api", "number-verify", "error", err)
+		h.opts.OnError(w, r, err)
+		return
+	}
+	h.opts.OnResult(w, r, result)
+}
+
+func (h *NumberVerifyCallbackHandler) handle(r *http.Request) (*NumberVerifyCallbackResult, error) {
+	ctx := r.Context()
+	query := r.URL.Query()
+	state := query.Get("state")
+	var req *types.NumberVerifyAuthRequest
+	if state != "" {
+		var err error
+		if req, err = h.opts.Store.Take(ctx, state); err != nil {
+			return nil, err
+		}
+	}
+	if code := query.Get("error"); code != "" {
+		return nil, utils.AuthorizationResponseError("number-verify", code, query.Get("error_description"))
+	}
+	if req == nil {
+		return nil, utils.InvalidRequestError("Unknown or expired state")
+	}
+	if req.PhoneNumber == "" {
+		return nil, utils.InvalidRequestError("Phone number is required to verify a number")
+	}
+
+	client, err := h.client.ForWithContext(ctx, types.NumberVerifyClientForParams{
+		Code:        query.Get("code"),
+		State:       state,
+		AuthRequest: req,
+		PhoneNumber: &req.PhoneNumber,
+	})
+	if err != nil {
+		return nil, err
+	}
+	result, err := client.VerifyNumberWithContext(ctx, nil, h.opts.Config)
+	if err != nil {
+		return nil, err
+	}
+	return &NumberVerifyCallbackResult{AuthRequest: req, Client: client, Result: result}, nil
+}
+
+func writeCallbackResult(w http.ResponseWriter, r *http.Request, result *NumberVerifyCallbackResult) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result.Result)
+}
+
+// writeCallbackError answers with the status text only, as the error may
+// carry upstream response details not meant for the user's browser.
+func writeCallbackError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusBadGateway
+	if errors.Is(err, utils.ErrInvalidRequest) || errors.Is(err, utils.ErrPermissionDenied) || errors.Is(err, utils.ErrInvalidToken) {
+		status = http.StatusBadRequest
+	}
+	http.Error(w, http.StatusText(status), status)
+}
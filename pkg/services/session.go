@@ -0,0 +1,42 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/GlideApis/sdk-go/pkg/utils"
+)
+
+// tokenSource returns the token source configured in settings, or a
+// TokenManager private to the calling service when none is set.
+func tokenSource(settings types.GlideSdkSettings) types.TokenSource {
+	if settings.TokenSource != nil {
+		return settings.TokenSource
+	}
+	return utils.NewTokenManager(settings)
+}
+
+// idTokenVerifier returns the ID token verifier configured in settings, or a
+// verifier private to the calling client when none is set.
+func idTokenVerifier(settings types.GlideSdkSettings) types.IDTokenVerifier {
+	if settings.IDTokenVerifier != nil {
+		return settings.IDTokenVerifier
+	}
+	return utils.NewIDTokenVerifier(settings)
+}
+
+// invalidateRejectedToken drops the cached token for scopes when the API
+// rejected it, e.g. because it was minted with a since rotated secret, so
+// that the next call mints a new one. Sessions passed in by the caller are
+// left alone.
+func invalidateRejectedToken(ctx context.Context, tokens types.TokenSource, confSession *types.Session, err error, scopes ...string) {
+	if confSession != nil || !(errors.Is(err, utils.ErrUnauthorized) || errors.Is(err, utils.ErrInvalidCredentials)) {
+		return
+	}
+	if invalidator, ok := tokens.(interface {
+		Invalidate(ctx context.Context, scopes ...string)
+	}); ok {
+		invalidator.Invalidate(ctx, scopes...)
+	}
+}
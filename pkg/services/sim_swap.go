@@ -1,15 +1,16 @@
 package services
 
 import (
-	"encoding/base64"
+	"context"
 	"encoding/json"
 	"fmt"
-	"net/url"
-	"strings"
+	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/GlideApis/sdk-go/pkg/types"
 	"github.com/GlideApis/sdk-go/pkg/utils"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type SimSwapCheckResponse struct {
@@ -22,42 +23,85 @@ type SimSwapRetrieveDateResponse struct {
 
 type SimSwapUserClient struct {
 	settings        types.GlideSdkSettings
+	logger          *slog.Logger
+	tracer          trace.Tracer
+	mu              sync.Mutex // guards session, consent and authReq
+	verifier        types.IDTokenVerifier
 	identifier      types.UserIdentifier
 	session         *types.Session
 	RequiresConsent bool
 	consentURL      string
-	authReqID       string
+	authReq         *cibaRequest
 }
 
 func NewSimSwapUserClient(settings types.GlideSdkSettings, identifier types.UserIdentifier) *SimSwapUserClient {
 	return &SimSwapUserClient{
 		settings:   settings,
+		logger:     utils.NewLogger(settings),
+		tracer:     utils.NewTracer(settings),
+		verifier:   idTokenVerifier(settings),
 		identifier: identifier,
 	}
 }
 
+// NeedsConsent reports whether the user must consent at GetConsentURL. Use
+// it instead of the RequiresConsent field while the client is shared with
+// other goroutines.
+func (c *SimSwapUserClient) NeedsConsent() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.RequiresConsent
+}
+
 func (c *SimSwapUserClient) GetConsentURL() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.consentURL
 }
 
+// IDTokenClaims verifies the ID token returned with the session's access
+// token and returns its claims. The session must have been started.
+func (c *SimSwapUserClient) IDTokenClaims(ctx context.Context) (*types.IDTokenClaims, error) {
+	c.mu.Lock()
+	session := c.session
+	c.mu.Unlock()
+	if session == nil || session.IDToken == "" {
+		return nil, utils.InvalidRequestError("Session has no ID token")
+	}
+	return c.verifier.Verify(ctx, session.IDToken, "")
+}
+
 // Check performs a SIM swap check
 func (c *SimSwapUserClient) Check(params types.SimSwapCheckParams, conf types.ApiConfig) (*SimSwapCheckResponse, error) {
+	return c.CheckWithContext(context.Background(), params, conf)
+}
+
+// CheckWithContext is like Check but bound to ctx.
+func (c *SimSwapUserClient) CheckWithContext(ctx context.Context, params types.SimSwapCheckParams, conf types.ApiConfig) (res *SimSwapCheckResponse, err error) {
+	ctx, span := utils.StartSpan(ctx, c.tracer, "sim-swap", "check")
+	defer func() { utils.EndSpan(span, err) }()
+	start := time.Now()
+	defer func() {
+		utils.LogOperation(ctx, c.logger, "sim-swap", "check", start, err)
+		emitFailure(ctx, c.settings, "sim-swap", "check", conf.SessionIdentifier, err)
+	}()
 	if c.settings.Internal.APIBaseURL == "" {
-		utils.Logger.Error("internal.apiBaseUrl is unset")
-		return nil, fmt.Errorf("[GlideClient] internal.apiBaseUrl is unset")
+		c.logger.Error("internal.apiBaseUrl is unset")
+		return nil, utils.ConfigError("internal.apiBaseUrl is unset")
 	}
 	phoneNumber := params.PhoneNumber
 	if phoneNumber == "" {
 		if phoneIdentifier, ok := c.identifier.(types.PhoneIdentifier); ok {
 			phoneNumber = phoneIdentifier.PhoneNumber
 		} else {
-			return nil, fmt.Errorf("[GlideClient] phone number not provided")
+			return nil, utils.InvalidRequestError("phone number not provided")
 		}
 	}
-	session, err := c.getSession(conf.Session)
+	session, err := c.getSession(ctx, conf.Session)
 	if err != nil {
 		return nil, fmt.Errorf("[GlideClient] Failed to get session: %w", err)
 	}
+	utils.SetSpanSessionOperator(ctx, session)
 	body := map[string]interface{}{
 		"phoneNumber": utils.FormatPhoneNumber(phoneNumber),
 	}
@@ -68,8 +112,13 @@ func (c *SimSwapUserClient) Check(params types.SimSwapCheckParams, conf types.Ap
 	if err != nil {
 		return nil, fmt.Errorf("[GlideClient] Failed to marshal request body: %w", err)
 	}
-	resp, err := utils.FetchX(c.settings.Internal.APIBaseURL+"/sim-swap/check", utils.FetchXInput{
-		Method: "POST",
+	resp, err := utils.FetchXWithContext(ctx, c.settings.Internal.APIBaseURL+"/sim-swap/check", utils.FetchXInput{
+		Client:     c.settings.HTTPClient,
+		Logger:     c.logger,
+		Retry:      c.settings.Retry,
+		Collector:  c.settings.Collector,
+		Idempotent: true,
+		Method:     "POST",
 		Headers: map[string]string{
 			"Content-Type":  "application/json",
 			"Authorization": "Bearer " + session.AccessToken,
@@ -77,11 +126,7 @@ func (c *SimSwapUserClient) Check(params types.SimSwapCheckParams, conf types.Ap
 		Body: string(bodyJSON),
 	})
 	if err != nil {
-		if fetchErr, ok := err.(*utils.FetchError); ok && fetchErr.Response.StatusCode == 404 {
-			return nil, fmt.Errorf("[GlideClient] Network ID not found for number %s", phoneNumber)
-		}
-		utils.Logger.Error("FetchX failed: %v", err)
-		return nil, fmt.Errorf("[GlideClient] FetchX failed: %w", err)
+		return nil, utils.NewAPIError("sim-swap", "check", err, session)
 	}
 	var result SimSwapCheckResponse
 	if err := resp.JSON(&result); err != nil {
@@ -92,8 +137,20 @@ func (c *SimSwapUserClient) Check(params types.SimSwapCheckParams, conf types.Ap
 
 // RetrieveDate retrieves the date of the latest SIM swap
 func (c *SimSwapUserClient) RetrieveDate(params types.SimSwapRetrieveDateParams, conf types.ApiConfig) (*SimSwapRetrieveDateResponse, error) {
+	return c.RetrieveDateWithContext(context.Background(), params, conf)
+}
+
+// RetrieveDateWithContext is like RetrieveDate but bound to ctx.
+func (c *SimSwapUserClient) RetrieveDateWithContext(ctx context.Context, params types.SimSwapRetrieveDateParams, conf types.ApiConfig) (res *SimSwapRetrieveDateResponse, err error) {
+	ctx, span := utils.StartSpan(ctx, c.tracer, "sim-swap", "retrieve-date")
+	defer func() { utils.EndSpan(span, err) }()
+	start := time.Now()
+	defer func() {
+		utils.LogOperation(ctx, c.logger, "sim-swap", "retrieve-date", start, err)
+		emitFailure(ctx, c.settings, "sim-swap", "retrieve-date", conf.SessionIdentifier, err)
+	}()
 	if c.settings.Internal.APIBaseURL == "" {
-		return nil, fmt.Errorf("[GlideClient] internal.apiBaseUrl is unset")
+		return nil, utils.ConfigError("internal.apiBaseUrl is unset")
 	}
 
 	phoneNumber := params.PhoneNumber
@@ -101,14 +158,15 @@ func (c *SimSwapUserClient) RetrieveDate(params types.SimSwapRetrieveDateParams,
 		if phoneIdentifier, ok := c.identifier.(types.PhoneIdentifier); ok {
 			phoneNumber = phoneIdentifier.PhoneNumber
 		} else {
-			return nil, fmt.Errorf("[GlideClient] phone number not provided")
+			return nil, utils.InvalidRequestError("phone number not provided")
 		}
 	}
 
-	session, err := c.getSession(conf.Session)
+	session, err := c.getSession(ctx, conf.Session)
 	if err != nil {
 		return nil, fmt.Errorf("[GlideClient] Failed to get session: %w", err)
 	}
+	utils.SetSpanSessionOperator(ctx, session)
 
 	body := map[string]string{
 		"phoneNumber": utils.FormatPhoneNumber(phoneNumber),
@@ -119,8 +177,13 @@ func (c *SimSwapUserClient) RetrieveDate(params types.SimSwapRetrieveDateParams,
 		return nil, fmt.Errorf("[GlideClient] Failed to marshal request body: %w", err)
 	}
 
-	resp, err := utils.FetchX(c.settings.Internal.APIBaseURL+"/sim-swap/retrieve-date", utils.FetchXInput{
-		Method: "POST",
+	resp, err := utils.FetchXWithContext(ctx, c.settings.Internal.APIBaseURL+"/sim-swap/retrieve-date", utils.FetchXInput{
+		Client:     c.settings.HTTPClient,
+		Logger:     c.logger,
+		Retry:      c.settings.Retry,
+		Collector:  c.settings.Collector,
+		Idempotent: true,
+		Method:     "POST",
 		Headers: map[string]string{
 			"Content-Type":  "application/json",
 			"Authorization": "Bearer " + session.AccessToken,
@@ -129,10 +192,7 @@ func (c *SimSwapUserClient) RetrieveDate(params types.SimSwapRetrieveDateParams,
 	})
 
 	if err != nil {
-		if fetchErr, ok := err.(*utils.FetchError); ok && fetchErr.Response.StatusCode == 404 {
-			return nil, fmt.Errorf("[GlideClient] Network ID not found for number %s", phoneNumber)
-		}
-		return nil, fmt.Errorf("[GlideClient] FetchX failed: %w", err)
+		return nil, utils.NewAPIError("sim-swap", "retrieve-date", err, session)
 	}
 
 	var result SimSwapRetrieveDateResponse
@@ -144,9 +204,25 @@ func (c *SimSwapUserClient) RetrieveDate(params types.SimSwapRetrieveDateParams,
 }
 
 func (c *SimSwapUserClient) StartSession() error {
-	if c.settings.ClientID == "" || c.settings.ClientSecret == "" {
-		return fmt.Errorf("[GlideClient] Client credentials are required to generate a new session")
-	}
+	return c.StartSessionWithContext(context.Background())
+}
+
+// StartSessionWithContext is like StartSession but bound to ctx.
+func (c *SimSwapUserClient) StartSessionWithContext(ctx context.Context) (err error) {
+	ctx, span := utils.StartSpan(ctx, c.tracer, "sim-swap", "backchannel-authentication")
+	defer func() { utils.EndSpan(span, err) }()
+	start := time.Now()
+	defer func() {
+		utils.LogOperation(ctx, c.logger, "sim-swap", "backchannel-authentication", start, err)
+		emitFailure(ctx, c.settings, "sim-swap", "backchannel-authentication", "", err)
+	}()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.startSession(ctx)
+}
+
+// startSession starts a backchannel authentication request; c.mu must be held.
+func (c *SimSwapUserClient) startSession(ctx context.Context) error {
 	var loginHint string
 	switch identifier := c.identifier.(type) {
 	case types.PhoneIdentifier:
@@ -154,119 +230,149 @@ func (c *SimSwapUserClient) StartSession() error {
 	case types.IpIdentifier:
 		loginHint = "ipport:" + identifier.IPAddress
 	}
-	data := url.Values{}
-	data.Set("scope", "sim-swap")
-	if loginHint != "" {
-		data.Set("login_hint", loginHint)
-	}
-	resp, err := utils.FetchX(c.settings.Internal.AuthBaseURL+"/oauth2/backchannel-authentication", utils.FetchXInput{
-		Method: "POST",
-		Headers: map[string]string{
-			"Content-Type":  "application/x-www-form-urlencoded",
-			"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(c.settings.ClientID+":"+c.settings.ClientSecret)),
-		},
-		Body: data.Encode(),
-	})
+	req, err := startCIBA(ctx, c.settings, c.logger, "sim-swap", loginHint)
 	if err != nil {
-		return fmt.Errorf("[GlideClient] FetchX failed: %w", err)
-	}
-	var body struct {
-		ConsentURL string `json:"consentUrl"`
-		AuthReqID  string `json:"auth_req_id"`
+		return err
 	}
-	if err := resp.JSON(&body); err != nil {
-		return fmt.Errorf("[GlideClient] Failed to parse response: %w", err)
-	}
-	if body.ConsentURL != "" {
+	if req.consentURL != "" {
 		c.RequiresConsent = true
-		c.consentURL = body.ConsentURL
+		c.consentURL = req.consentURL
 	}
-	c.authReqID = body.AuthReqID
-
+	c.authReq = req
 	return nil
 }
 
-func (c *SimSwapUserClient) getSession(confSession *types.Session) (*types.Session, error) {
+func (c *SimSwapUserClient) getSession(ctx context.Context, confSession *types.Session) (*types.Session, error) {
 	if confSession != nil {
-		utils.Logger.Debug("Using provided session")
+		c.logger.Debug("Using provided session")
 		return confSession, nil
 	}
 
-	if c.session != nil && c.session.ExpiresAt > time.Now().Add(time.Minute).Unix() && contains(c.session.Scopes, "sim-swap") {
-		utils.Logger.Debug("Using cached session")
-		return c.session, nil
+	// Ping and push notifications are awaited without the lock, so that
+	// the client stays usable while the user decides.
+	c.mu.Lock()
+	session, req := c.cachedSession(), c.authReq
+	c.mu.Unlock()
+	if session != nil {
+		c.logger.Debug("Using cached session")
+		return session, nil
+	}
+	if req != nil {
+		if err := req.awaitNotification(ctx); err != nil {
+			return nil, err
+		}
 	}
 
-	utils.Logger.Debug("Generating new session")
-	session, err := c.generateNewSession()
+	// Holding the lock across the token call makes concurrent callers wait
+	// for a single CIBA token exchange instead of each starting their own.
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if session := c.cachedSession(); session != nil {
+		c.logger.Debug("Using cached session")
+		return session, nil
+	}
+
+	c.logger.Debug("Generating new session")
+	session, err := c.generateNewSession(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate new session: %w", err)
 	}
-	c.authReqID = ""
+	c.authReq = nil
 	c.session = session
 	return session, nil
 }
 
-// PollAndWaitForSession continuously polls for a valid session
+// cachedSession returns the session if it is valid for another minute; c.mu
+// must be held.
+func (c *SimSwapUserClient) cachedSession() *types.Session {
+	if c.session != nil && c.session.ExpiresAt > time.Now().Add(time.Minute).Unix() && contains(c.session.Scopes, "sim-swap") {
+		return c.session
+	}
+	return nil
+}
+
+// PollAndWaitForSession polls for a session until the user approves or
+// denies the backchannel authentication request, or it expires.
 func (c *SimSwapUserClient) PollAndWaitForSession() error {
-	for {
-		_, err := c.getSession(nil)
-		if err == nil {
-			return nil
+	return c.PollAndWaitForSessionWithContext(context.Background())
+}
+
+// PollAndWaitForSessionWithContext is like PollAndWaitForSession but stops
+// when ctx is done.
+func (c *SimSwapUserClient) PollAndWaitForSessionWithContext(ctx context.Context) error {
+	return c.PollAndWaitForSessionWithOptions(ctx, types.CIBAPollOptions{})
+}
+
+// PollAndWaitForSessionWithOptions polls at the interval requested by the
+// authorization server, slowing down when asked to. It returns an error
+// matching utils.ErrPermissionDenied when the user denies the request and
+// utils.ErrAuthorizationExpired when it expires.
+func (c *SimSwapUserClient) PollAndWaitForSessionWithOptions(ctx context.Context, opts types.CIBAPollOptions) error {
+	return waitForCIBA(ctx, "sim-swap", opts, func(ctx context.Context) (cibaRequest, error) {
+		_, err := c.getSession(ctx, nil)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.authReq == nil {
+			return cibaRequest{}, err
 		}
-		time.Sleep(5 * time.Second)
+		return *c.authReq, err
+	})
+}
+
+// pendingRequest returns the backchannel authentication request awaiting
+// the user, if any.
+func (c *SimSwapUserClient) pendingRequest() (cibaRequest, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.authReq == nil {
+		return cibaRequest{}, false
 	}
+	return *c.authReq, true
 }
 
-// generateNewSession generates a new session
-func (c *SimSwapUserClient) generateNewSession() (*types.Session, error) {
-	if c.settings.ClientID == "" || c.settings.ClientSecret == "" {
-		return nil, fmt.Errorf("[GlideClient] Client credentials are required to generate a new session")
+// resume makes req, started elsewhere, the request awaiting the user.
+func (c *SimSwapUserClient) resume(req *cibaRequest) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.RequiresConsent = req.consentURL != ""
+	c.consentURL = req.consentURL
+	c.authReq = req
+}
+
+// abandon drops the request awaiting the user, if any.
+func (c *SimSwapUserClient) abandon() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.authReq != nil {
+		c.authReq.close()
+		c.authReq = nil
 	}
+}
 
-	if c.authReqID == "" {
-		if err := c.StartSession(); err != nil {
+// generateNewSession redeems the pending backchannel authentication request
+// for a session, starting one first if needed; c.mu must be held. The
+// request is kept while the user has not answered yet or the poll failed
+// transiently.
+func (c *SimSwapUserClient) generateNewSession(ctx context.Context) (*types.Session, error) {
+	if c.authReq == nil {
+		if err := c.startSession(ctx); err != nil {
 			return nil, err
 		}
 	}
 
-	if c.authReqID == "" {
+	if c.authReq.authReqID == "" {
+		c.authReq.close()
+		c.authReq = nil
 		return nil, fmt.Errorf("[GlideClient] Failed to start session")
 	}
 
-	data := url.Values{}
-	data.Set("grant_type", "urn:openid:params:grant-type:ciba")
-	data.Set("auth_req_id", c.authReqID)
-
-	resp, err := utils.FetchX(c.settings.Internal.AuthBaseURL+"/oauth2/token", utils.FetchXInput{
-		Method: "POST",
-		Headers: map[string]string{
-			"Content-Type":  "application/x-www-form-urlencoded",
-			"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(c.settings.ClientID+":"+c.settings.ClientSecret)),
-		},
-		Body: data.Encode(),
-	})
-
-	if err != nil {
-		c.authReqID = ""
-		return nil, fmt.Errorf("[GlideClient] FetchX failed: %w", err)
-	}
-
-	var body struct {
-		AccessToken string `json:"access_token"`
-		ExpiresIn   int64  `json:"expires_in"`
-		Scope       string `json:"scope"`
-	}
-	if err := resp.JSON(&body); err != nil {
-		return nil, fmt.Errorf("[GlideClient] Failed to parse response: %w", err)
+	session, err := redeemCIBA(ctx, c.settings, c.logger, "sim-swap", c.authReq)
+	if err != nil && cibaKept(ctx, err) {
+		return nil, err
 	}
-
-	return &types.Session{
-		AccessToken: body.AccessToken,
-		ExpiresAt:   time.Now().Unix() + body.ExpiresIn,
-		// ExpiresAt:   time.Now().Add(time.Duration(body.ExpiresIn) * time.Second),
-		Scopes: strings.Split(body.Scope, " "),
-	}, nil
+	c.authReq.close()
+	c.authReq = nil
+	return session, err
 }
 
 // SimSwapClient is the main client for SIM swap operations
@@ -281,14 +387,26 @@ func NewSimSwapClient(settings types.GlideSdkSettings) *SimSwapClient {
 
 // For creates a SimSwapUserClient for a specific user
 func (c *SimSwapClient) For(identifier types.UserIdentifier) (*SimSwapUserClient, error) {
+	return c.ForWithContext(context.Background(), identifier)
+}
+
+// ForWithContext is like For but bound to ctx.
+func (c *SimSwapClient) ForWithContext(ctx context.Context, identifier types.UserIdentifier) (*SimSwapUserClient, error) {
 	client := NewSimSwapUserClient(c.settings, identifier)
-	err := client.StartSession()
+	err := client.StartSessionWithContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return client, nil
 }
 
+// NewConsentHandler returns a handler running the consent workflow of c.
+func (c *SimSwapClient) NewConsentHandler(opts ConsentOptions[*SimSwapUserClient]) *ConsentHandler[*SimSwapUserClient] {
+	return newConsentHandler(c.settings, "sim-swap", c.ForWithContext, func(identifier types.UserIdentifier) *SimSwapUserClient {
+		return NewSimSwapUserClient(c.settings, identifier)
+	}, opts)
+}
+
 func (c *SimSwapClient) GetHello() string {
 	return "Hello"
 }
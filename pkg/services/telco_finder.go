@@ -1,41 +1,56 @@
 package services
 
 import (
-	"encoding/base64"
+	"context"
 	"encoding/json"
 	"fmt"
-	"net/url"
-	"strings"
+	"log/slog"
 	"time"
 
 	"github.com/GlideApis/sdk-go/pkg/types"
 	"github.com/GlideApis/sdk-go/pkg/utils"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type TelcoFinderClient struct {
 	settings types.GlideSdkSettings
-	session  *types.Session
+	logger   *slog.Logger
+	tracer   trace.Tracer
+	tokens   types.TokenSource
 }
 
 func NewTelcoFinderClient(settings types.GlideSdkSettings) *TelcoFinderClient {
 	return &TelcoFinderClient{
 		settings: settings,
+		logger:   utils.NewLogger(settings),
+		tracer:   utils.NewTracer(settings),
+		tokens:   tokenSource(settings),
 	}
 }
 
 // NetworkIdForNumber resolves the network ID for a given phone number
 func (c *TelcoFinderClient) NetworkIdForNumber(phoneNumber string, conf types.ApiConfig) (*types.TelcoFinderNetworkIdResponse, error) {
+	return c.NetworkIdForNumberWithContext(context.Background(), phoneNumber, conf)
+}
+
+// NetworkIdForNumberWithContext is like NetworkIdForNumber but bound to ctx.
+func (c *TelcoFinderClient) NetworkIdForNumberWithContext(ctx context.Context, phoneNumber string, conf types.ApiConfig) (res *types.TelcoFinderNetworkIdResponse, err error) {
+	ctx, span := utils.StartSpan(ctx, c.tracer, "telco-finder", "resolve-network-id")
+	defer func() { utils.EndSpan(span, err) }()
+	start := time.Now()
+	defer func() {
+		utils.LogOperation(ctx, c.logger, "telco-finder", "resolve-network-id", start, err)
+		emitFailure(ctx, c.settings, "telco-finder", "resolve-network-id", conf.SessionIdentifier, err)
+	}()
 	if c.settings.Internal.APIBaseURL == "" {
-		utils.Logger.Error("internal.apiBaseUrl is unset")
-		return nil, fmt.Errorf("[GlideClient] internal.apiBaseUrl is unset")
+		c.logger.Error("internal.apiBaseUrl is unset")
+		return nil, utils.ConfigError("internal.apiBaseUrl is unset")
 	}
 
-	session, err := c.getSession(conf.Session)
+	session, err := c.getSession(ctx, conf.Session)
 	if err != nil {
-		utils.Logger.Error("Failed to get session: %v", err)
 		return nil, fmt.Errorf("[GlideClient] Failed to get session: %w", err)
 	}
-	utils.Logger.Debug("Using session with AccessToken: %s...", session.AccessToken)
 
 	body, err := json.Marshal(map[string]string{
 		"phoneNumber": utils.FormatPhoneNumber(phoneNumber),
@@ -44,11 +59,14 @@ func (c *TelcoFinderClient) NetworkIdForNumber(phoneNumber string, conf types.Ap
 		return nil, fmt.Errorf("[GlideClient] Failed to marshal request body: %w", err)
 	}
 
-	utils.Logger.Debug("Fetching network ID for number: %s...", phoneNumber)
-	utils.Logger.Debug("Request body: %s", body)
-	utils.Logger.Debug("APIBaseURL: %s", c.settings.Internal.APIBaseURL+"/telco-finder/v1/resolve-network-id")
-	resp, err := utils.FetchX(c.settings.Internal.APIBaseURL+"/telco-finder/v1/resolve-network-id", utils.FetchXInput{
-		Method: "POST",
+	c.logger.Debug("Fetching network ID for number", "api", "telco-finder", "phoneNumber", phoneNumber)
+	resp, err := utils.FetchXWithContext(ctx, c.settings.Internal.APIBaseURL+"/telco-finder/v1/resolve-network-id", utils.FetchXInput{
+		Client:     c.settings.HTTPClient,
+		Logger:     c.logger,
+		Retry:      c.settings.Retry,
+		Collector:  c.settings.Collector,
+		Idempotent: true,
+		Method:     "POST",
 		Headers: map[string]string{
 			"Content-Type":  "application/json",
 			"Authorization": "Bearer " + session.AccessToken,
@@ -56,7 +74,9 @@ func (c *TelcoFinderClient) NetworkIdForNumber(phoneNumber string, conf types.Ap
 		Body: string(body),
 	})
 	if err != nil {
-		return nil, fmt.Errorf("[GlideClient] FetchX failed for getting Network ID not found for number: %w", err)
+		err = utils.NewAPIError("telco-finder", "resolve-network-id", err, session)
+		invalidateRejectedToken(ctx, c.tokens, conf.Session, err, "telco-finder")
+		return nil, err
 	}
 
 	var result types.TelcoFinderNetworkIdResponse
@@ -69,20 +89,37 @@ func (c *TelcoFinderClient) NetworkIdForNumber(phoneNumber string, conf types.Ap
 
 // LookupIp looks up telco information for an IP address
 func (c *TelcoFinderClient) LookupIp(ip string, conf types.ApiConfig) (*types.TelcoFinderSearchResponse, error) {
-	return c.lookup(fmt.Sprintf("ipport:%s", ip), conf)
+	return c.LookupIpWithContext(context.Background(), ip, conf)
+}
+
+// LookupIpWithContext is like LookupIp but bound to ctx.
+func (c *TelcoFinderClient) LookupIpWithContext(ctx context.Context, ip string, conf types.ApiConfig) (*types.TelcoFinderSearchResponse, error) {
+	return c.lookup(ctx, fmt.Sprintf("ipport:%s", ip), conf)
 }
 
 // LookupNumber looks up telco information for a phone number
 func (c *TelcoFinderClient) LookupNumber(phoneNumber string, conf types.ApiConfig) (*types.TelcoFinderSearchResponse, error) {
-	return c.lookup(fmt.Sprintf("tel:%s", utils.FormatPhoneNumber(phoneNumber)), conf)
+	return c.LookupNumberWithContext(context.Background(), phoneNumber, conf)
 }
 
-func (c *TelcoFinderClient) lookup(subject string, conf types.ApiConfig) (*types.TelcoFinderSearchResponse, error) {
+// LookupNumberWithContext is like LookupNumber but bound to ctx.
+func (c *TelcoFinderClient) LookupNumberWithContext(ctx context.Context, phoneNumber string, conf types.ApiConfig) (*types.TelcoFinderSearchResponse, error) {
+	return c.lookup(ctx, fmt.Sprintf("tel:%s", utils.FormatPhoneNumber(phoneNumber)), conf)
+}
+
+func (c *TelcoFinderClient) lookup(ctx context.Context, subject string, conf types.ApiConfig) (res *types.TelcoFinderSearchResponse, err error) {
+	ctx, span := utils.StartSpan(ctx, c.tracer, "telco-finder", "search")
+	defer func() { utils.EndSpan(span, err) }()
+	start := time.Now()
+	defer func() {
+		utils.LogOperation(ctx, c.logger, "telco-finder", "search", start, err)
+		emitFailure(ctx, c.settings, "telco-finder", "search", conf.SessionIdentifier, err)
+	}()
 	if c.settings.Internal.APIBaseURL == "" {
-		return nil, fmt.Errorf("[GlideClient] internal.apiBaseUrl is unset")
+		return nil, utils.ConfigError("internal.apiBaseUrl is unset")
 	}
 
-	session, err := c.getSession(conf.Session)
+	session, err := c.getSession(ctx, conf.Session)
 	if err != nil {
 		return nil, err
 	}
@@ -94,8 +131,13 @@ func (c *TelcoFinderClient) lookup(subject string, conf types.ApiConfig) (*types
 		return nil, err
 	}
 
-	resp, err := utils.FetchX(c.settings.Internal.APIBaseURL+"/telco-finder/v1/search", utils.FetchXInput{
-		Method: "POST",
+	resp, err := utils.FetchXWithContext(ctx, c.settings.Internal.APIBaseURL+"/telco-finder/v1/search", utils.FetchXInput{
+		Client:     c.settings.HTTPClient,
+		Logger:     c.logger,
+		Retry:      c.settings.Retry,
+		Collector:  c.settings.Collector,
+		Idempotent: true,
+		Method:     "POST",
 		Headers: map[string]string{
 			"Content-Type":  "application/json",
 			"Authorization": "Bearer " + session.AccessToken,
@@ -103,9 +145,8 @@ func (c *TelcoFinderClient) lookup(subject string, conf types.ApiConfig) (*types
 		Body: string(body),
 	})
 	if err != nil {
-		if fetchErr, ok := err.(*utils.FetchError); ok && fetchErr.Response.StatusCode == 404 {
-			return nil, fmt.Errorf("[GlideClient] Lookup failed for subject %s", subject)
-		}
+		err = utils.NewAPIError("telco-finder", "search", err, session)
+		invalidateRejectedToken(ctx, c.tokens, conf.Session, err, "telco-finder")
 		return nil, err
 	}
 
@@ -113,83 +154,20 @@ func (c *TelcoFinderClient) lookup(subject string, conf types.ApiConfig) (*types
 	if err := resp.JSON(&result); err != nil {
 		return nil, err
 	}
-
-	result.Properties.OperatorID = result.Properties.OperatorID // Assuming the field name is already correct in Go struct
+	utils.SetSpanOperator(ctx, result.Properties.OperatorID)
+	if result.Properties.OperatorID != "" {
+		emit(ctx, c.settings, types.Event{Type: types.EventOperatorResolved, API: "telco-finder", Operation: "search", SessionID: conf.SessionIdentifier, Operator: result.Properties.OperatorID})
+	}
 
 	return &result, nil
 }
 
-func (c *TelcoFinderClient) getSession(confSession *types.Session) (*types.Session, error) {
+func (c *TelcoFinderClient) getSession(ctx context.Context, confSession *types.Session) (*types.Session, error) {
 	if confSession != nil {
-		utils.Logger.Debug("Using provided session")
+		c.logger.Debug("Using provided session")
 		return confSession, nil
 	}
-
-	if c.session != nil && c.session.ExpiresAt > time.Now().Add(time.Minute).Unix() && contains(c.session.Scopes, "telco-finder") {
-		utils.Logger.Debug("Using cached session")
-		return c.session, nil
-	}
-
-	utils.Logger.Debug("Generating new session")
-	session, err := c.generateNewSession()
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate new session: %w", err)
-	}
-
-	c.session = session
-	return session, nil
-}
-
-func (c *TelcoFinderClient) generateNewSession() (*types.Session, error) {
-	if c.settings.ClientID == "" || c.settings.ClientSecret == "" {
-		utils.Logger.Error("Client credentials are required to generate a new session")
-		return nil, fmt.Errorf("[GlideClient] Client credentials are required to generate a new session")
-	}
-
-	basicAuth := base64.StdEncoding.EncodeToString([]byte(c.settings.ClientID + ":" + c.settings.ClientSecret))
-
-	resp, err := utils.FetchX(c.settings.Internal.AuthBaseURL+"/oauth2/token", utils.FetchXInput{
-		Method: "POST",
-		Headers: map[string]string{
-			"Content-Type":  "application/x-www-form-urlencoded",
-			"Authorization": "Basic " + basicAuth,
-		},
-		Body: url.Values{
-			"grant_type": {"client_credentials"},
-			"scope":      {"telco-finder"},
-		}.Encode(),
-	})
-	if err != nil {
-		if fetchErr, ok := err.(*utils.FetchError); ok {
-			if fetchErr.Response.StatusCode == 401 {
-				return nil, fmt.Errorf("[GlideClient] Invalid client credentials")
-			} else if fetchErr.Response.StatusCode == 400 {
-				var data map[string]interface{}
-				if err := json.Unmarshal([]byte(fetchErr.Data), &data); err == nil {
-					if data["error"] == "invalid_scope" {
-						return nil, fmt.Errorf("[GlideClient] Client does not have required scopes to access this method")
-					}
-				}
-				return nil, fmt.Errorf("[GlideClient] Invalid request")
-			}
-		}
-		return nil, err
-	}
-
-	var body struct {
-		AccessToken string `json:"access_token"`
-		ExpiresIn   int64  `json:"expires_in"`
-		Scope       string `json:"scope"`
-	}
-	if err := resp.JSON(&body); err != nil {
-		return nil, err
-	}
-
-	return &types.Session{
-		AccessToken: body.AccessToken,
-		ExpiresAt:   time.Now().Unix() + body.ExpiresIn,
-		Scopes:      strings.Split(body.Scope, " "),
-	}, nil
+	return c.tokens.Token(ctx, "telco-finder")
 }
 
 func contains(slice []string, item string) bool {
@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/GlideApis/sdk-go/pkg/glide"
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/GlideApis/sdk-go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// unsignedJWT encodes claims as a JWT with a placeholder signature.
+func unsignedJWT(claims map[string]any) string {
+	payload, _ := json.Marshal(claims)
+	return base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+}
+
+func TestNumberVerifyAuthRequest(t *testing.T) {
+	var idTokenNonce string
+	var exchanges int
+	issuer := newTestIssuer(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if issuer.serve(w, r) {
+			return
+		}
+		exchanges++
+		r.ParseForm()
+		assert.Equal(t, "authorization_code", r.Form.Get("grant_type"))
+		assert.Equal(t, "https://example.com/callback", r.Form.Get("redirect_uri"))
+		assert.Equal(t, "the-verifier", r.Form.Get("code_verifier"))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token","expires_in":3600,"scope":"openid","id_token":%q}`, issuer.sign(t, map[string]any{"nonce": idTokenNonce}))
+	}))
+	defer server.Close()
+	issuer.url = server.URL
+
+	glideClient, err := glide.NewGlideClient(NewOfflineSettings(server.URL))
+	assert.NoError(t, err)
+	authRequest := &types.NumberVerifyAuthRequest{State: "the-state", Nonce: "the-nonce", CodeVerifier: "the-verifier"}
+
+	t.Run("GetAuthRequest adds a PKCE challenge", func(t *testing.T) {
+		req, err := glideClient.NumberVerify.GetAuthRequest()
+		assert.NoError(t, err)
+		parsed, err := url.Parse(req.URL)
+		assert.NoError(t, err)
+		query := parsed.Query()
+		assert.Equal(t, req.State, query.Get("state"))
+		assert.Equal(t, req.Nonce, query.Get("nonce"))
+		assert.Len(t, req.CodeVerifier, 43)
+		assert.Equal(t, utils.CodeChallengeS256(req.CodeVerifier), query.Get("code_challenge"))
+		assert.Equal(t, "S256", query.Get("code_challenge_method"))
+
+		authURL, err := glideClient.NumberVerify.GetAuthURL()
+		assert.NoError(t, err)
+		assert.NotContains(t, authURL, "code_challenge", "GetAuthURL keeps exchanges without a verifier working")
+	})
+
+	t.Run("uses the client ID of the credentials provider", func(t *testing.T) {
+		settings := NewOfflineSettings(server.URL)
+		settings.ClientID, settings.ClientSecret = "", ""
+		settings.Credentials = utils.StaticCredentials{ClientID: "provided-client", ClientSecret: "secret"}
+		providerClient, err := glide.NewGlideClient(settings)
+		assert.NoError(t, err)
+		req, err := providerClient.NumberVerify.GetAuthRequest()
+		assert.NoError(t, err)
+		parsed, err := url.Parse(req.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, "provided-client", parsed.Query().Get("client_id"))
+	})
+
+	t.Run("exchanges the code when state and nonce match", func(t *testing.T) {
+		idTokenNonce = "the-nonce"
+		_, err := glideClient.NumberVerify.For(types.NumberVerifyClientForParams{Code: "code", State: "the-state", AuthRequest: authRequest})
+		assert.NoError(t, err)
+	})
+
+	t.Run("rejects a mismatched state before exchanging", func(t *testing.T) {
+		exchanges = 0
+		_, err := glideClient.NumberVerify.For(types.NumberVerifyClientForParams{Code: "code", State: "forged", AuthRequest: authRequest})
+		assert.ErrorIs(t, err, utils.ErrInvalidRequest)
+		assert.Zero(t, exchanges)
+	})
+
+	t.Run("rejects an id_token with another nonce", func(t *testing.T) {
+		idTokenNonce = "replayed"
+		_, err := glideClient.NumberVerify.For(types.NumberVerifyClientForParams{Code: "code", State: "the-state", AuthRequest: authRequest})
+		assert.ErrorIs(t, err, utils.ErrInvalidToken)
+	})
+
+	t.Run("exposes the verified id_token claims", func(t *testing.T) {
+		idTokenNonce = "the-nonce"
+		userClient, err := glideClient.NumberVerify.For(types.NumberVerifyClientForParams{Code: "code", State: "the-state", AuthRequest: authRequest})
+		assert.NoError(t, err)
+		claims, err := userClient.IDTokenClaims(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "user-1", claims.Subject)
+	})
+}
@@ -0,0 +1,184 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/glide"
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/GlideApis/sdk-go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCIBANotifications(t *testing.T) {
+	var mu sync.Mutex
+	var notificationToken, apiToken string
+	var tokenRequests int
+	backchannelResponse := `{"auth_req_id":"req-1","expires_in":60}`
+	issuer := newTestIssuer(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if issuer.serve(w, r) {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/oauth2/backchannel-authentication":
+			r.ParseForm()
+			notificationToken = r.Form.Get("client_notification_token")
+			fmt.Fprint(w, backchannelResponse)
+		case "/oauth2/token":
+			tokenRequests++
+			fmt.Fprint(w, `{"access_token":"polled-token","expires_in":3600,"scope":"sim-swap"}`)
+		case "/sim-swap/check":
+			apiToken = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			fmt.Fprint(w, `{"swapped":true}`)
+		}
+	}))
+	defer server.Close()
+	issuer.url = server.URL
+
+	start := func(t *testing.T, mode types.CIBAMode) *glide.GlideClient {
+		settings := NewOfflineSettings(server.URL)
+		settings.CIBAMode = mode
+		glideClient, err := glide.NewGlideClient(settings)
+		assert.NoError(t, err)
+		mu.Lock()
+		tokenRequests, apiToken = 0, ""
+		mu.Unlock()
+		return glideClient
+	}
+	notify := func(glideClient *glide.GlideClient, token, body string) int {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/ciba", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		glideClient.CIBANotificationHandler().ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+	currentToken := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return notificationToken
+	}
+
+	t.Run("ping resumes Check with a token request", func(t *testing.T) {
+		glideClient := start(t, types.CIBAPing)
+		userClient, err := glideClient.SimSwap.For(types.PhoneIdentifier{PhoneNumber: "+555123456789"})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, currentToken())
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			assert.Equal(t, http.StatusNoContent, notify(glideClient, currentToken(), `{"auth_req_id":"req-1"}`))
+		}()
+		res, err := userClient.Check(types.SimSwapCheckParams{}, types.ApiConfig{})
+		assert.NoError(t, err)
+		assert.True(t, res.Swapped)
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, 1, tokenRequests)
+		assert.Equal(t, "polled-token", apiToken)
+	})
+
+	t.Run("push delivers the tokens", func(t *testing.T) {
+		glideClient := start(t, types.CIBAPush)
+		userClient, err := glideClient.SimSwap.For(types.PhoneIdentifier{PhoneNumber: "+555123456789"})
+		assert.NoError(t, err)
+
+		idToken := issuer.sign(t, map[string]any{"urn:openid:params:jwt:claim:auth_req_id": "req-1"})
+		body := fmt.Sprintf(`{"auth_req_id":"req-1","access_token":"pushed-token","token_type":"Bearer","expires_in":3600,"id_token":%q}`, idToken)
+		assert.Equal(t, http.StatusNoContent, notify(glideClient, currentToken(), body))
+		assert.NoError(t, userClient.PollAndWaitForSessionWithContext(context.Background()))
+		_, err = userClient.Check(types.SimSwapCheckParams{}, types.ApiConfig{})
+		assert.NoError(t, err)
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Zero(t, tokenRequests)
+		assert.Equal(t, "pushed-token", apiToken)
+	})
+
+	t.Run("push requires an ID token bound to the request", func(t *testing.T) {
+		for name, idToken := range map[string]string{
+			"missing": "",
+			"unbound": issuer.sign(t, map[string]any{"urn:openid:params:jwt:claim:auth_req_id": "req-2"}),
+		} {
+			glideClient := start(t, types.CIBAPush)
+			userClient, err := glideClient.SimSwap.For(types.PhoneIdentifier{PhoneNumber: "+555123456789"})
+			assert.NoError(t, err)
+			body := fmt.Sprintf(`{"auth_req_id":"req-1","access_token":"pushed-token","token_type":"Bearer","expires_in":3600,"id_token":%q}`, idToken)
+			assert.Equal(t, http.StatusNoContent, notify(glideClient, currentToken(), body))
+			assert.ErrorIs(t, userClient.PollAndWaitForSession(), utils.ErrInvalidToken, name)
+		}
+	})
+
+	t.Run("push without expires_in keeps the session", func(t *testing.T) {
+		glideClient := start(t, types.CIBAPush)
+		userClient, err := glideClient.SimSwap.For(types.PhoneIdentifier{PhoneNumber: "+555123456789"})
+		assert.NoError(t, err)
+		idToken := issuer.sign(t, map[string]any{"urn:openid:params:jwt:claim:auth_req_id": "req-1"})
+		body := fmt.Sprintf(`{"auth_req_id":"req-1","access_token":"pushed-token","token_type":"Bearer","id_token":%q}`, idToken)
+		assert.Equal(t, http.StatusNoContent, notify(glideClient, currentToken(), body))
+		assert.NoError(t, userClient.PollAndWaitForSession())
+		_, err = userClient.Check(types.SimSwapCheckParams{}, types.ApiConfig{})
+		assert.NoError(t, err)
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, "pushed-token", apiToken)
+	})
+
+	t.Run("waiting without expires_in leaves the client usable", func(t *testing.T) {
+		mu.Lock()
+		backchannelResponse = `{"auth_req_id":"req-1"}`
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			backchannelResponse = `{"auth_req_id":"req-1","expires_in":60}`
+			mu.Unlock()
+		}()
+		glideClient := start(t, types.CIBAPing)
+		userClient, err := glideClient.SimSwap.For(types.PhoneIdentifier{PhoneNumber: "+555123456789"})
+		assert.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		waited := make(chan error)
+		go func() { waited <- userClient.PollAndWaitForSessionWithContext(ctx) }()
+		time.Sleep(20 * time.Millisecond)
+
+		claimed := make(chan struct{})
+		go func() {
+			userClient.IDTokenClaims(context.Background())
+			userClient.GetConsentURL()
+			close(claimed)
+		}()
+		select {
+		case <-claimed:
+		case <-time.After(time.Second):
+			t.Fatal("client is locked while waiting for the notification")
+		}
+		cancel()
+		assert.ErrorIs(t, <-waited, context.Canceled)
+	})
+
+	t.Run("push reports denial", func(t *testing.T) {
+		glideClient := start(t, types.CIBAPush)
+		userClient, err := glideClient.SimSwap.For(types.PhoneIdentifier{PhoneNumber: "+555123456789"})
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, notify(glideClient, currentToken(), `{"auth_req_id":"req-1","error":"access_denied"}`))
+		err = userClient.PollAndWaitForSession()
+		assert.ErrorIs(t, err, utils.ErrPermissionDenied)
+	})
+
+	t.Run("rejects unauthenticated notifications", func(t *testing.T) {
+		glideClient := start(t, types.CIBAPing)
+		_, err := glideClient.SimSwap.For(types.PhoneIdentifier{PhoneNumber: "+555123456789"})
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, notify(glideClient, "forged", `{"auth_req_id":"req-1"}`))
+		assert.Equal(t, http.StatusBadRequest, notify(glideClient, currentToken(), `{"auth_req_id":"other"}`))
+	})
+}
@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/glide"
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/GlideApis/sdk-go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// cibaServer answers backchannel authentication requests with interval and
+// expiresIn, and CIBA token polls with the next of answers, the last one
+// repeating. An empty answer grants a token.
+type cibaServer struct {
+	mu                sync.Mutex
+	interval          int
+	expiresIn         int
+	answers           []string
+	polls             int
+	requests          int
+	notificationToken string
+}
+
+func (s *cibaServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/oauth2/backchannel-authentication":
+		r.ParseForm()
+		s.notificationToken = r.Form.Get("client_notification_token")
+		s.requests++
+		fmt.Fprintf(w, `{"auth_req_id":"req-1","interval":%d,"expires_in":%d}`, s.interval, s.expiresIn)
+	case "/oauth2/token":
+		answer := s.answers[min(s.polls, len(s.answers)-1)]
+		s.polls++
+		if answer == "unavailable" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if answer != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error":%q}`, answer)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"token","expires_in":3600,"scope":"sim-swap kyc-match"}`)
+	}
+}
+
+func TestCIBAPolling(t *testing.T) {
+	run := func(t *testing.T, ctx context.Context, server *cibaServer) ([]types.CIBAProgress, error) {
+		httpServer := httptest.NewServer(server)
+		t.Cleanup(httpServer.Close)
+		glideClient, err := glide.NewGlideClient(NewOfflineSettings(httpServer.URL))
+		assert.NoError(t, err)
+		userClient, err := glideClient.SimSwap.For(types.PhoneIdentifier{PhoneNumber: "+555123456789"})
+		assert.NoError(t, err)
+		var progress []types.CIBAProgress
+		err = userClient.PollAndWaitForSessionWithOptions(ctx, types.CIBAPollOptions{
+			OnProgress: func(p types.CIBAProgress) { progress = append(progress, p) },
+		})
+		return progress, err
+	}
+
+	t.Run("waits the advertised interval", func(t *testing.T) {
+		start := time.Now()
+		progress, err := run(t, context.Background(), &cibaServer{interval: 1, expiresIn: 60, answers: []string{"authorization_pending", ""}})
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), time.Second)
+		if assert.Len(t, progress, 2) {
+			assert.Equal(t, types.CIBAPending, progress[0].Status)
+			assert.Equal(t, time.Second, progress[0].Interval)
+			assert.Equal(t, types.CIBAApproved, progress[1].Status)
+			assert.Equal(t, 2, progress[1].Attempt)
+		}
+	})
+
+	t.Run("slows down and respects the deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		server := &cibaServer{interval: 1, expiresIn: 60, answers: []string{"slow_down"}}
+		progress, err := run(t, ctx, server)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, 1, server.polls)
+		if assert.Len(t, progress, 1) {
+			assert.Equal(t, types.CIBASlowDown, progress[0].Status)
+			assert.Equal(t, 6*time.Second, progress[0].Interval)
+		}
+	})
+
+	for answer, want := range map[string]struct {
+		err    error
+		status types.CIBAStatus
+	}{
+		"access_denied": {utils.ErrPermissionDenied, types.CIBADenied},
+		"expired_token": {utils.ErrAuthorizationExpired, types.CIBAExpired},
+	} {
+		t.Run("stops on "+answer, func(t *testing.T) {
+			server := &cibaServer{interval: 1, expiresIn: 60, answers: []string{answer}}
+			progress, err := run(t, context.Background(), server)
+			assert.ErrorIs(t, err, want.err)
+			assert.Equal(t, 1, server.polls)
+			if assert.Len(t, progress, 1) {
+				assert.Equal(t, want.status, progress[0].Status)
+			}
+		})
+	}
+
+	t.Run("keeps the request through server errors", func(t *testing.T) {
+		// the default policy retries each poll twice before it fails
+		server := &cibaServer{interval: 1, expiresIn: 60, answers: []string{"unavailable", "unavailable", "unavailable", ""}}
+		progress, err := run(t, context.Background(), server)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, server.requests, "no new backchannel request is started")
+		if assert.Len(t, progress, 2) {
+			assert.Equal(t, types.CIBAUnavailable, progress[0].Status)
+			assert.Equal(t, types.CIBAApproved, progress[1].Status)
+		}
+	})
+
+	t.Run("stops once the request expires", func(t *testing.T) {
+		progress, err := run(t, context.Background(), &cibaServer{interval: 1, expiresIn: 1, answers: []string{"authorization_pending"}})
+		assert.ErrorIs(t, err, utils.ErrAuthorizationExpired)
+		if assert.Len(t, progress, 2) {
+			assert.Equal(t, types.CIBAExpired, progress[1].Status)
+		}
+	})
+}
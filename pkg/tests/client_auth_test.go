@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/glide"
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/GlideApis/sdk-go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// verifyTestJWT checks an RS256 or ES256 JWT against pub and returns its
+// header and claims.
+func verifyTestJWT(t *testing.T, token string, pub crypto.PublicKey) (header, claims map[string]any) {
+	t.Helper()
+	parts := strings.Split(token, ".")
+	if !assert.Len(t, parts, 3) {
+		return nil, nil
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	assert.NoError(t, err)
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		assert.NoError(t, rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature))
+	case *ecdsa.PublicKey:
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		assert.True(t, ecdsa.Verify(pub, digest[:], r, s), "valid ES256 signature")
+	}
+	for i, v := range []*map[string]any{&header, &claims} {
+		data, err := base64.RawURLEncoding.DecodeString(parts[i])
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(data, v))
+	}
+	return header, claims
+}
+
+func TestClientAuthentication(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	for _, tc := range []struct {
+		name string
+		key  crypto.Signer
+		alg  string
+	}{
+		{"private_key_jwt with RSA", rsaKey, "RS256"},
+		{"private_key_jwt with EC", ecKey, "ES256"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				r.ParseForm()
+				assert.Empty(t, r.Header.Get("Authorization"))
+				assert.Equal(t, "test-client", r.Form.Get("client_id"))
+				assert.Equal(t, "urn:ietf:params:oauth:client-assertion-type:jwt-bearer", r.Form.Get("client_assertion_type"))
+				header, claims := verifyTestJWT(t, r.Form.Get("client_assertion"), tc.key.Public())
+				assert.Equal(t, tc.alg, header["alg"])
+				assert.Equal(t, "key-1", header["kid"])
+				assert.Equal(t, "test-client", claims["iss"])
+				assert.Equal(t, "test-client", claims["sub"])
+				assert.Equal(t, "http://"+r.Host+"/oauth2/token", claims["aud"], "defaults to the token endpoint")
+				assert.NotEmpty(t, claims["jti"])
+				WriteTokenResponse(w, "token", "telco-finder", 3600)
+			}))
+			defer server.Close()
+
+			glideClient, err := glide.New(
+				glide.WithoutEnv(),
+				glide.WithCredentials("test-client", ""),
+				glide.WithBaseURLs(server.URL, server.URL),
+				glide.WithPrivateKeyJWT(tc.key, "key-1"),
+			)
+			assert.NoError(t, err)
+			session, err := glideClient.Settings.TokenSource.Token(context.Background(), "telco-finder")
+			assert.NoError(t, err)
+			assert.Equal(t, "token", session.AccessToken)
+		})
+	}
+
+	t.Run("tls_client_auth presents the client certificate", func(t *testing.T) {
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.ParseForm()
+			assert.Empty(t, r.Header.Get("Authorization"))
+			assert.Equal(t, "test-client", r.Form.Get("client_id"))
+			if assert.Len(t, r.TLS.PeerCertificates, 1) {
+				assert.Equal(t, "test-client", r.TLS.PeerCertificates[0].Subject.CommonName)
+			}
+			WriteTokenResponse(w, "token", "telco-finder", 3600)
+		}))
+		server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+		server.StartTLS()
+		defer server.Close()
+
+		glideClient, err := glide.New(
+			glide.WithoutEnv(),
+			glide.WithCredentials("test-client", ""),
+			glide.WithBaseURLs(server.URL, server.URL),
+			glide.WithHTTPClient(server.Client()),
+			glide.WithTLSClientAuth(selfSignedCertificate(t, ecKey, "test-client")),
+		)
+		assert.NoError(t, err)
+		_, err = glideClient.Settings.TokenSource.Token(context.Background(), "telco-finder")
+		assert.NoError(t, err)
+	})
+
+	t.Run("private_key_jwt requires a key", func(t *testing.T) {
+		settings := NewOfflineSettings("https://oidc.example.com")
+		settings.ClientAuth = types.ClientAuth{Method: types.PrivateKeyJWT}
+		_, err := glide.NewGlideClient(settings)
+		assert.ErrorIs(t, err, utils.ErrConfiguration)
+		assert.ErrorContains(t, err, "clientAuth.privateKey")
+	})
+
+	t.Run("parses PEM private keys", func(t *testing.T) {
+		der, err := x509.MarshalPKCS8PrivateKey(ecKey)
+		assert.NoError(t, err)
+		key, err := utils.ParsePrivateKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+		assert.NoError(t, err)
+		assert.True(t, ecKey.Equal(key))
+
+		key, err = utils.ParsePrivateKeyPEM(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}))
+		assert.NoError(t, err)
+		assert.True(t, rsaKey.Equal(key))
+	})
+}
+
+func selfSignedCertificate(t *testing.T, key *ecdsa.PrivateKey, commonName string) tls.Certificate {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	assert.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/GlideApis/sdk-go/pkg/glide"
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/GlideApis/sdk-go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsCollector(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth2/token":
+			WriteTokenResponse(w, "token", "telco-finder", 3600)
+		case "/telco-finder/v1/search":
+			w.Write([]byte(`{"subject":"tel:+555123456789","properties":{"operator_Id":"Test Operator"}}`))
+		default:
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	collector := utils.NewMemoryCollector()
+	settings := NewOfflineSettings(server.URL)
+	settings.Collector = collector
+	settings.Retry = fastRetryPolicy()
+	glideClient, err := glide.NewGlideClient(settings)
+	assert.NoError(t, err)
+
+	_, err = glideClient.TelcoFinder.LookupNumber("+555123456789", types.ApiConfig{})
+	assert.NoError(t, err)
+	_, err = glideClient.TelcoFinder.NetworkIdForNumber("+555123456789", types.ApiConfig{})
+	assert.Error(t, err)
+
+	snapshot := collector.Snapshot()
+	assert.Equal(t, uint64(1), snapshot.Requests[utils.RequestKey{Method: "POST", Endpoint: "/oauth2/token", Status: 200}])
+	assert.Equal(t, uint64(1), snapshot.Requests[utils.RequestKey{Method: "POST", Endpoint: "/telco-finder/v1/search", Status: 200}])
+	// 429 is retried, so every attempt is counted.
+	assert.Equal(t, uint64(3), snapshot.Requests[utils.RequestKey{Method: "POST", Endpoint: "/telco-finder/v1/resolve-network-id", Status: 429}])
+	assert.Equal(t, uint64(3), snapshot.Errors[utils.ErrorKey{Method: "POST", Endpoint: "/telco-finder/v1/resolve-network-id", Type: "rate_limited"}])
+	assert.Equal(t, uint64(1), snapshot.Latency[utils.EndpointKey{Method: "POST", Endpoint: "/telco-finder/v1/search"}].Count)
+	assert.Equal(t, uint64(1), snapshot.TokenRefreshes[utils.TokenRefreshKey{Scope: "telco-finder", Success: true}])
+
+	recorder := httptest.NewRecorder()
+	collector.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+	assert.Contains(t, body, "# TYPE glide_http_request_duration_seconds histogram")
+	assert.Contains(t, body, `glide_http_requests_total{method="POST",endpoint="/telco-finder/v1/resolve-network-id",status="429"} 3`)
+	assert.Contains(t, body, `glide_http_request_duration_seconds_count{method="POST",endpoint="/telco-finder/v1/search"} 1`)
+	assert.Contains(t, body, `glide_errors_total{method="POST",endpoint="/telco-finder/v1/resolve-network-id",type="rate_limited"} 3`)
+	assert.Contains(t, body, `glide_token_refreshes_total{scope="telco-finder",result="success"} 1`)
+	assert.True(t, strings.HasSuffix(body, "\n"))
+}
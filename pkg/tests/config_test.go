@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/glide"
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/GlideApis/sdk-go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestConfiguration(t *testing.T) {
+	t.Run("UseEnv controls env lookups", func(t *testing.T) {
+		t.Setenv("GLIDE_CLIENT_ID", "env-client")
+		t.Setenv("GLIDE_API_BASE_URL", "https://env.example.com")
+
+		_, err := glide.NewGlideClient(types.GlideSdkSettings{})
+		assert.ErrorContains(t, err, "clientId is required")
+
+		glideClient, err := glide.NewGlideClient(types.GlideSdkSettings{UseEnv: true})
+		assert.NoError(t, err)
+		assert.Equal(t, "env-client", glideClient.Settings.ClientID)
+		assert.Equal(t, "https://env.example.com", glideClient.Settings.Internal.APIBaseURL)
+	})
+
+	t.Run("loads YAML, JSON and dotenv files", func(t *testing.T) {
+		yamlPath := writeConfigFile(t, "glide.yaml", `
+clientId: yaml-client
+clientSecret: yaml-secret
+internal:
+  apiBaseUrl: https://api.example.com
+  logLevel: debug
+retry:
+  maxAttempts: 5
+  initialBackoff: 100ms
+  maxBackoff: 2s
+`)
+		settings, err := glide.LoadConfigFile(yamlPath)
+		assert.NoError(t, err)
+		assert.Equal(t, "yaml-client", settings.ClientID)
+		assert.Equal(t, "https://api.example.com", settings.Internal.APIBaseURL)
+		assert.Equal(t, types.DEBUG, settings.Internal.LogLevel)
+		if assert.NotNil(t, settings.Retry) {
+			assert.Equal(t, 5, settings.Retry.MaxAttempts)
+			assert.Equal(t, 100*time.Millisecond, settings.Retry.InitialBackoff)
+			assert.Equal(t, 2*time.Second, settings.Retry.MaxBackoff)
+		}
+
+		jsonPath := writeConfigFile(t, "glide.json", `{"clientId":"json-client","redirectUri":"https://example.com/cb","retry":{"initialBackoff":"1s"}}`)
+		settings, err = glide.LoadConfigFile(jsonPath)
+		assert.NoError(t, err)
+		assert.Equal(t, "json-client", settings.ClientID)
+		assert.Equal(t, "https://example.com/cb", settings.RedirectURI)
+		assert.Equal(t, time.Second, settings.Retry.InitialBackoff)
+
+		envPath := writeConfigFile(t, "glide.env", "GLIDE_CLIENT_ID=dotenv-client\nGLIDE_AUTH_BASE_URL=https://auth.example.com\n")
+		settings, err = glide.LoadConfigFile(envPath)
+		assert.NoError(t, err)
+		assert.Equal(t, "dotenv-client", settings.ClientID)
+		assert.Equal(t, "https://auth.example.com", settings.Internal.AuthBaseURL)
+		_, exists := os.LookupEnv("GLIDE_AUTH_BASE_URL")
+		assert.False(t, exists, "dotenv files must not change the environment")
+	})
+
+	t.Run("explicit > file > env > defaults", func(t *testing.T) {
+		t.Setenv("GLIDE_CLIENT_ID", "env-client")
+		t.Setenv("GLIDE_API_BASE_URL", "https://env-api.example.com")
+		t.Setenv("GLIDE_REDIRECT_URI", "https://env.example.com/cb")
+		path := writeConfigFile(t, "glide.yml", "clientId: file-client\ninternal:\n  apiBaseUrl: https://file-api.example.com\n")
+
+		glideClient, err := glide.New(glide.WithCredentials("explicit-client", "secret"), glide.WithConfigFile(path))
+		assert.NoError(t, err)
+		assert.Equal(t, "explicit-client", glideClient.Settings.ClientID)
+		assert.Equal(t, "https://file-api.example.com", glideClient.Settings.Internal.APIBaseURL)
+		assert.Equal(t, "https://env.example.com/cb", glideClient.Settings.RedirectURI)
+		assert.Equal(t, "https://oidc.gateway-x.io", glideClient.Settings.Internal.AuthBaseURL)
+	})
+
+	t.Run("invalid files are reported with other errors", func(t *testing.T) {
+		path := writeConfigFile(t, "glide.yaml", "clientID: typo\n")
+		_, err := glide.New(glide.WithoutEnv(), glide.WithConfigFile(path))
+		assert.True(t, errors.Is(err, utils.ErrConfiguration))
+		assert.ErrorContains(t, err, "parsing config file glide.yaml")
+		assert.ErrorContains(t, err, "clientId is required")
+
+		_, err = glide.LoadConfigFile(writeConfigFile(t, "glide.toml", ""))
+		assert.ErrorContains(t, err, "unsupported config file type")
+	})
+}
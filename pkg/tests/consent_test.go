@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GlideApis/sdk-go/pkg/glide"
+	"github.com/GlideApis/sdk-go/pkg/services"
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/GlideApis/sdk-go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsent(t *testing.T) {
+	server := &cibaServer{interval: 1, expiresIn: 60, answers: []string{""}}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	store := utils.NewFileConsentStore(filepath.Join(t.TempDir(), "consents.json"))
+
+	newHandler := func(t *testing.T, opts services.ConsentOptions[*services.SimSwapUserClient]) *services.ConsentHandler[*services.SimSwapUserClient] {
+		glideClient, err := glide.NewGlideClient(NewOfflineSettings(httpServer.URL))
+		assert.NoError(t, err)
+		opts.Store = store
+		return glideClient.SimSwap.NewConsentHandler(opts)
+	}
+	serve := func(handler http.Handler, query string) int {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/consent?"+query, nil))
+		return recorder.Code
+	}
+	identifier := types.PhoneIdentifier{PhoneNumber: "+555123456789"}
+
+	t.Run("completes on another instance", func(t *testing.T) {
+		consent, err := newHandler(t, services.ConsentOptions[*services.SimSwapUserClient]{}).Start(context.Background(), identifier)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, consent.State())
+
+		var result *services.ConsentResult[*services.SimSwapUserClient]
+		other := newHandler(t, services.ConsentOptions[*services.SimSwapUserClient]{
+			OnResult: func(w http.ResponseWriter, r *http.Request, res *services.ConsentResult[*services.SimSwapUserClient]) {
+				result = res
+			},
+		})
+		assert.Equal(t, http.StatusOK, serve(other, "state="+consent.State()))
+		assert.Equal(t, 1, server.polls)
+		if assert.NotNil(t, result) {
+			assert.Equal(t, "req-1", result.Consent.AuthReqID)
+			assert.Equal(t, "+555123456789", result.Consent.PhoneNumber)
+			assert.NoError(t, result.Client.PollAndWaitForSession())
+		}
+		assert.Equal(t, http.StatusBadRequest, serve(other, "state="+consent.State()))
+	})
+
+	t.Run("waits on the starting instance", func(t *testing.T) {
+		handler := newHandler(t, services.ConsentOptions[*services.SimSwapUserClient]{})
+		consent, err := handler.Start(context.Background(), identifier)
+		assert.NoError(t, err)
+		read := make(chan struct{})
+		go func() {
+			defer close(read)
+			consent.ConsentURL()
+			consent.Client().NeedsConsent()
+		}()
+		assert.NoError(t, consent.Wait(context.Background()))
+		<-read
+		assert.Equal(t, http.StatusBadRequest, serve(handler, "state="+consent.State()))
+	})
+
+	t.Run("rejects unknown states and denials", func(t *testing.T) {
+		handler := newHandler(t, services.ConsentOptions[*services.SimSwapUserClient]{})
+		assert.Equal(t, http.StatusBadRequest, serve(handler, "state=unknown"))
+		consent, err := handler.Start(context.Background(), identifier)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, serve(handler, "state="+consent.State()+"&error=access_denied"))
+		assert.Equal(t, http.StatusBadRequest, serve(handler, "state="+consent.State()))
+	})
+
+	t.Run("releases the notification of a denied consent", func(t *testing.T) {
+		settings := NewOfflineSettings(httpServer.URL)
+		settings.CIBAMode = types.CIBAPing
+		glideClient, err := glide.NewGlideClient(settings)
+		assert.NoError(t, err)
+		handler := glideClient.SimSwap.NewConsentHandler(services.ConsentOptions[*services.SimSwapUserClient]{})
+		consent, err := handler.Start(context.Background(), identifier)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, serve(handler, "state="+consent.State()+"&error=access_denied"))
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/ciba", strings.NewReader(`{"auth_req_id":"req-1"}`))
+		req.Header.Set("Authorization", "Bearer "+server.notificationToken)
+		glideClient.CIBANotificationHandler().ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	})
+}
@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/glide"
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/GlideApis/sdk-go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextCancellation(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	t.Run("FetchXWithContext honours deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err := utils.FetchXWithContext(ctx, server.URL, utils.FetchXInput{Method: "GET"})
+		assert.Error(t, err)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), "expected deadline exceeded, got %v", err)
+		assert.Less(t, time.Since(start), 2*time.Second)
+	})
+
+	t.Run("service call honours cancellation", func(t *testing.T) {
+		glideClient, err := glide.NewGlideClient(types.GlideSdkSettings{
+			ClientID:     "client",
+			ClientSecret: "secret",
+			Internal: types.InternalSettings{
+				AuthBaseURL: server.URL,
+				APIBaseURL:  server.URL,
+			},
+		})
+		assert.NoError(t, err)
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+		session := &types.Session{AccessToken: "token", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+		_, err = glideClient.TelcoFinder.LookupNumberWithContext(ctx, "+555123456789", types.ApiConfig{Session: session})
+		assert.Error(t, err)
+		assert.True(t, errors.Is(err, context.Canceled), "expected context canceled, got %v", err)
+	})
+
+	t.Run("cancellation during backoff reports the context error", func(t *testing.T) {
+		unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer unavailable.Close()
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+		policy := &types.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second}
+		_, err := utils.FetchXWithContext(ctx, unavailable.URL, utils.FetchXInput{Method: "GET", Retry: policy})
+		assert.True(t, errors.Is(err, context.Canceled), "expected context canceled, got %v", err)
+		assert.Equal(t, "canceled", utils.ErrorType(err))
+		var fetchErr *utils.FetchError
+		if assert.ErrorAs(t, err, &fetchErr) {
+			assert.Equal(t, http.StatusServiceUnavailable, fetchErr.Response.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/glide"
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/GlideApis/sdk-go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// rotatingServer accepts only the current secret at the token endpoint and
+// only tokens minted with it at the API.
+type rotatingServer struct {
+	mu     sync.Mutex
+	secret string
+	mints  int
+}
+
+func (s *rotatingServer) rotate(secret string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secret = secret
+}
+
+func (s *rotatingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	secret := s.secret
+	s.mu.Unlock()
+	switch r.URL.Path {
+	case "/oauth2/token":
+		if _, got, _ := r.BasicAuth(); got != secret {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		s.mu.Lock()
+		s.mints++
+		s.mu.Unlock()
+		WriteTokenResponse(w, "token-"+secret, "telco-finder", 3600)
+	case "/telco-finder/v1/search":
+		if r.Header.Get("Authorization") != "Bearer token-"+secret {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"status":401,"code":"UNAUTHENTICATED","message":"token revoked"}`))
+			return
+		}
+		w.Write([]byte(`{"subject":"tel:+555123456789","properties":{"operator_Id":"Test Operator"}}`))
+	}
+}
+
+func writeCredentialsFile(t *testing.T, path, secret string, modTime time.Time) {
+	t.Helper()
+	assert.NoError(t, os.WriteFile(path, []byte(`{"clientId":"test-client","clientSecret":"`+secret+`"}`), 0o600))
+	assert.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestCredentialsProvider(t *testing.T) {
+	t.Run("file credentials pick up a rotated secret without rebuilding the client", func(t *testing.T) {
+		server := &rotatingServer{secret: "old"}
+		ts := httptest.NewServer(server)
+		defer ts.Close()
+
+		path := filepath.Join(t.TempDir(), "credentials.json")
+		writeCredentialsFile(t, path, "old", time.Now().Add(-time.Hour))
+		settings := NewOfflineSettings(ts.URL)
+		settings.ClientSecret = ""
+		settings.Credentials = utils.NewFileCredentials(path)
+		glideClient, err := glide.NewGlideClient(settings)
+		assert.NoError(t, err)
+
+		ctx := context.Background()
+		_, err = glideClient.TelcoFinder.LookupNumberWithContext(ctx, "+555123456789", types.ApiConfig{})
+		assert.NoError(t, err)
+
+		server.rotate("new")
+		writeCredentialsFile(t, path, "new", time.Now())
+
+		_, err = glideClient.TelcoFinder.LookupNumberWithContext(ctx, "+555123456789", types.ApiConfig{})
+		assert.ErrorIs(t, err, utils.ErrUnauthorized, "the revoked token is rejected once")
+		_, err = glideClient.TelcoFinder.LookupNumberWithContext(ctx, "+555123456789", types.ApiConfig{})
+		assert.NoError(t, err, "a new token is minted with the rotated secret")
+		server.mu.Lock()
+		assert.Equal(t, 2, server.mints)
+		server.mu.Unlock()
+	})
+
+	t.Run("invalid_client drops cached tokens", func(t *testing.T) {
+		server := &rotatingServer{secret: "old"}
+		ts := httptest.NewServer(server)
+		defer ts.Close()
+
+		creds := &switchableCredentials{secret: "old"}
+		settings := NewOfflineSettings(ts.URL)
+		settings.Credentials = creds
+		manager := utils.NewTokenManager(settings)
+		ctx := context.Background()
+
+		_, err := manager.Token(ctx, "telco-finder")
+		assert.NoError(t, err)
+		server.rotate("new")
+		_, err = manager.Token(ctx, "magic-auth")
+		assert.ErrorIs(t, err, utils.ErrInvalidCredentials)
+
+		creds.set("new")
+		session, err := manager.Token(ctx, "telco-finder")
+		assert.NoError(t, err)
+		assert.Equal(t, "token-new", session.AccessToken, "the token minted with the old secret is not served")
+	})
+
+	t.Run("env credentials are read on every call", func(t *testing.T) {
+		t.Setenv("GLIDE_CLIENT_ID", "env-client")
+		t.Setenv("GLIDE_CLIENT_SECRET", "first")
+		creds, err := utils.ClientCredentials(context.Background(), types.GlideSdkSettings{Credentials: utils.EnvCredentials{}})
+		assert.NoError(t, err)
+		assert.Equal(t, types.Credentials{ClientID: "env-client", ClientSecret: "first"}, creds)
+
+		t.Setenv("GLIDE_CLIENT_SECRET", "second")
+		creds, err = utils.ClientCredentials(context.Background(), types.GlideSdkSettings{Credentials: utils.EnvCredentials{}})
+		assert.NoError(t, err)
+		assert.Equal(t, "second", creds.ClientSecret)
+	})
+
+	t.Run("falls back to the settings", func(t *testing.T) {
+		settings := types.GlideSdkSettings{ClientID: "id", ClientSecret: "secret"}
+		creds, err := utils.ClientCredentials(context.Background(), settings)
+		assert.NoError(t, err)
+		assert.Equal(t, types.Credentials{ClientID: "id", ClientSecret: "secret"}, creds)
+
+		settings.Credentials = utils.StaticCredentials{ClientSecret: "provided"}
+		creds, err = utils.ClientCredentials(context.Background(), settings)
+		assert.NoError(t, err)
+		assert.Equal(t, types.Credentials{ClientID: "id", ClientSecret: "provided"}, creds)
+
+		_, err = utils.ClientCredentials(context.Background(), types.GlideSdkSettings{ClientID: "id"})
+		assert.ErrorIs(t, err, utils.ErrConfiguration)
+	})
+}
+
+type switchableCredentials struct {
+	mu     sync.Mutex
+	secret string
+}
+
+func (c *switchableCredentials) set(secret string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.secret = secret
+}
+
+func (c *switchableCredentials) Credentials(ctx context.Context) (types.Credentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return types.Credentials{ClientID: "test-client", ClientSecret: c.secret}, nil
+}
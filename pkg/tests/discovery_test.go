@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/glide"
+	"github.com/GlideApis/sdk-go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiscovery(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	advertise := true
+	issuer := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			if !advertise {
+				http.NotFound(w, r)
+				return
+			}
+			advertised := issuer
+			if advertised == "" {
+				advertised = "http://" + r.Host
+			}
+			json.NewEncoder(w).Encode(map[string]string{
+				"issuer":                 advertised,
+				"authorization_endpoint": "https://login.example.com/authorize",
+				"token_endpoint":         "http://" + r.Host + "/v2/token",
+			})
+		case "/v2/token", "/oauth2/token":
+			WriteTokenResponse(w, "token", "magic-auth", 3600)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	newClient := func(t *testing.T) *glide.GlideClient {
+		mu.Lock()
+		paths = nil
+		mu.Unlock()
+		settings := NewOfflineSettings(server.URL)
+		settings.UseDiscovery = true
+		glideClient, err := glide.NewGlideClient(settings)
+		assert.NoError(t, err)
+		return glideClient
+	}
+	requested := func(path string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		n := 0
+		for _, p := range paths {
+			if p == path {
+				n++
+			}
+		}
+		return n
+	}
+
+	t.Run("uses the advertised endpoints", func(t *testing.T) {
+		glideClient := newClient(t)
+		_, err := glideClient.Settings.TokenSource.Token(context.Background(), "magic-auth")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, requested("/v2/token"))
+		assert.Zero(t, requested("/oauth2/token"))
+
+		authURL, err := glideClient.NumberVerify.GetAuthURL()
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(authURL, "https://login.example.com/authorize?"), authURL)
+
+		endpoints := glideClient.Settings.Endpoints.Endpoints(context.Background())
+		assert.Equal(t, server.URL, endpoints.Issuer)
+		assert.Equal(t, server.URL+"/oauth2/backchannel-authentication", endpoints.BackchannelAuthenticationEndpoint, "unadvertised endpoints keep their default path")
+		assert.Equal(t, 1, requested("/.well-known/openid-configuration"))
+	})
+
+	t.Run("rejects a configuration of another issuer", func(t *testing.T) {
+		mu.Lock()
+		issuer = "https://issuer.example.com"
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			issuer = ""
+			mu.Unlock()
+		}()
+		glideClient := newClient(t)
+		_, err := glideClient.Settings.TokenSource.Token(context.Background(), "magic-auth")
+		assert.NoError(t, err)
+		assert.Zero(t, requested("/v2/token"))
+		assert.Equal(t, 1, requested("/oauth2/token"))
+
+		_, err = utils.NewDiscovery(glideClient.Settings).Document(context.Background())
+		assert.ErrorIs(t, err, utils.ErrUpstream)
+		assert.ErrorContains(t, err, "issuer")
+	})
+
+	t.Run("concurrent loads share a request", func(t *testing.T) {
+		mu.Lock()
+		paths = nil
+		mu.Unlock()
+		settings := NewOfflineSettings(server.URL)
+		settings.UseDiscovery = true
+		discovery := utils.NewDiscovery(settings)
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				doc, err := discovery.Document(context.Background())
+				if assert.NoError(t, err) {
+					assert.Equal(t, server.URL, doc.Issuer)
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 1, requested("/.well-known/openid-configuration"))
+	})
+
+	t.Run("waiting for a load honours the context", func(t *testing.T) {
+		started, release := make(chan struct{}), make(chan struct{})
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			http.NotFound(w, r)
+		}))
+		defer slow.Close()
+		defer close(release)
+		settings := NewOfflineSettings(slow.URL)
+		settings.UseDiscovery = true
+		discovery := utils.NewDiscovery(settings)
+		go discovery.Document(context.Background())
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := discovery.Document(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("falls back to the default paths", func(t *testing.T) {
+		mu.Lock()
+		advertise = false
+		mu.Unlock()
+		glideClient := newClient(t)
+		for _, scope := range []string{"magic-auth", "telco-finder"} {
+			_, err := glideClient.Settings.TokenSource.Token(context.Background(), scope)
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, 2, requested("/oauth2/token"))
+		assert.Equal(t, 1, requested("/.well-known/openid-configuration"), "failed loads are not retried on every request")
+	})
+}
@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/glide"
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/GlideApis/sdk-go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypedErrors(t *testing.T) {
+	newClient := func(t *testing.T, handler http.HandlerFunc) *glide.GlideClient {
+		server := httptest.NewServer(handler)
+		t.Cleanup(server.Close)
+		settings := NewOfflineSettings(server.URL)
+		settings.Retry = &types.RetryPolicy{MaxAttempts: 1}
+		glideClient, err := glide.NewGlideClient(settings)
+		assert.NoError(t, err)
+		return glideClient
+	}
+	session := &types.Session{AccessToken: "token", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+
+	t.Run("not found carries status and request id", func(t *testing.T) {
+		glideClient := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Request-Id", "req-123")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"status":404,"code":"NOT_FOUND","message":"unknown subject"}`))
+		})
+		_, err := glideClient.TelcoFinder.LookupNumber("+555123456789", types.ApiConfig{Session: session})
+		assert.True(t, errors.Is(err, utils.ErrNotFound))
+		var gErr *utils.GlideError
+		assert.True(t, errors.As(err, &gErr))
+		assert.Equal(t, http.StatusNotFound, gErr.StatusCode)
+		assert.Equal(t, "req-123", gErr.RequestID)
+		assert.Equal(t, "telco-finder", gErr.API)
+		assert.Equal(t, "search", gErr.Operation)
+		var fetchErr *utils.FetchError
+		assert.True(t, errors.As(err, &fetchErr), "underlying FetchError is still reachable")
+	})
+
+	t.Run("token errors are classified", func(t *testing.T) {
+		cases := []struct {
+			status int
+			body   string
+			want   error
+		}{
+			{http.StatusUnauthorized, `{"error":"invalid_client"}`, utils.ErrInvalidCredentials},
+			{http.StatusBadRequest, `{"error":"invalid_scope"}`, utils.ErrInsufficientScope},
+			{http.StatusBadRequest, `{"error":"invalid_request"}`, utils.ErrInvalidRequest},
+			{http.StatusTooManyRequests, ``, utils.ErrRateLimited},
+			{http.StatusBadGateway, ``, utils.ErrUpstream},
+		}
+		for _, tc := range cases {
+			glideClient := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				w.Write([]byte(tc.body))
+			})
+			_, err := glideClient.MagicAuth.StartAuth(types.MagicAuthStartProps{PhoneNumber: "+555123456789"}, types.ApiConfig{})
+			assert.True(t, errors.Is(err, tc.want), "status %d: got %v", tc.status, err)
+		}
+	})
+
+	t.Run("error bodies are decoded", func(t *testing.T) {
+		cases := []struct {
+			status      int
+			body        string
+			code        string
+			description string
+			want        error
+		}{
+			{http.StatusForbidden, `{"status":403,"code":"PERMISSION_DENIED","message":"Client does not have sufficient permissions"}`,
+				utils.CodePermissionDenied, "Client does not have sufficient permissions", utils.ErrPermissionDenied},
+			{http.StatusNotFound, `{"status":404,"code":"IDENTIFIER_NOT_FOUND","message":"Device identifier not found"}`,
+				utils.CodeIdentifierNotFound, "Device identifier not found", utils.ErrNotFound},
+			{http.StatusBadRequest, `{"error":"invalid_scope","error_description":"scope sim-swap not granted"}`,
+				"invalid_scope", "scope sim-swap not granted", utils.ErrInsufficientScope},
+			{http.StatusBadRequest, `{"type":"about:blank","title":"Bad Request","status":400,"detail":"phoneNumber is malformed"}`,
+				"", "phoneNumber is malformed", utils.ErrInvalidRequest},
+		}
+		for _, tc := range cases {
+			glideClient := newClient(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tc.status)
+				w.Write([]byte(tc.body))
+			})
+			_, err := glideClient.TelcoFinder.LookupNumber("+555123456789", types.ApiConfig{Session: session})
+			var gErr *utils.GlideError
+			if assert.True(t, errors.As(err, &gErr)) {
+				assert.Equal(t, tc.code, gErr.Code)
+				assert.Equal(t, tc.description, gErr.Description)
+				assert.NotNil(t, gErr.ErrorBody)
+				assert.Contains(t, gErr.Error(), tc.description)
+			}
+			assert.True(t, errors.Is(err, tc.want), "%s: got %v", tc.body, err)
+		}
+	})
+
+	t.Run("configuration errors", func(t *testing.T) {
+		_, err := glide.NewGlideClient(types.GlideSdkSettings{
+			Internal: types.InternalSettings{AuthBaseURL: "http://localhost"},
+		})
+		if err == nil {
+			t.Skip("GLIDE_CLIENT_ID is set in the environment")
+		}
+		assert.True(t, errors.Is(err, utils.ErrConfiguration))
+	})
+}
@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/GlideApis/sdk-go/pkg/glide"
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/GlideApis/sdk-go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []types.Event
+}
+
+func (r *eventRecorder) OnEvent(_ context.Context, event types.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *eventRecorder) Events() []types.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := r.events
+	r.events = nil
+	return events
+}
+
+func eventTypes(events []types.Event) []types.EventType {
+	var result []types.EventType
+	for _, event := range events {
+		result = append(result, event.Type)
+	}
+	return result
+}
+
+func TestLifecycleEvents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth2/token":
+			WriteTokenResponse(w, "token", "magic-auth", 3600)
+		case "/oauth2/backchannel-authentication":
+			w.Write([]byte(`{"auth_req_id":"req-1","consentUrl":"https://example.com/consent"}`))
+		case "/magic-auth/verification/start":
+			w.Write([]byte(`{"type":"MAGIC","operatorId":"Test Operator"}`))
+		case "/magic-auth/verification/check":
+			w.Write([]byte(`{"verified":false}`))
+		default:
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	configured := &eventRecorder{}
+	settings := NewOfflineSettings(server.URL)
+	settings.Observer = configured
+	settings.Retry = fastRetryPolicy()
+	glideClient, err := glide.NewGlideClient(settings)
+	assert.NoError(t, err)
+	subscriber := &eventRecorder{}
+	unsubscribe := glideClient.Subscribe(subscriber)
+	conf := types.ApiConfig{SessionIdentifier: "session-1"}
+
+	t.Run("magic auth start and verify", func(t *testing.T) {
+		_, err := glideClient.MagicAuth.StartAuth(types.MagicAuthStartProps{PhoneNumber: "+555123456789"}, conf)
+		assert.NoError(t, err)
+		_, err = glideClient.MagicAuth.VerifyAuth(types.MagicAuthVerifyProps{PhoneNumber: "+555123456789", Code: "1234"}, conf)
+		assert.NoError(t, err)
+
+		events := configured.Events()
+		assert.Equal(t, []types.EventType{types.EventAuthStarted, types.EventOperatorResolved, types.EventUnverified}, eventTypes(events))
+		assert.Equal(t, "Test Operator", events[1].Operator)
+		assert.Equal(t, "session-1", events[2].SessionID)
+		assert.Equal(t, "verify", events[2].Operation)
+		assert.False(t, events[2].Time.IsZero())
+		assert.Equal(t, eventTypes(events), eventTypes(subscriber.Events()))
+	})
+
+	t.Run("consent required", func(t *testing.T) {
+		_, err := glideClient.SimSwap.For(types.PhoneIdentifier{PhoneNumber: "+555123456789"})
+		assert.NoError(t, err)
+
+		events := configured.Events()
+		assert.Equal(t, []types.EventType{types.EventAuthStarted, types.EventConsentRequired}, eventTypes(events))
+		assert.Equal(t, "https://example.com/consent", events[1].ConsentURL)
+		subscriber.Events()
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		unsubscribe()
+		_, err := glideClient.TelcoFinder.NetworkIdForNumber("+555123456789", conf)
+		assert.Error(t, err)
+
+		events := configured.Events()
+		if assert.Equal(t, []types.EventType{types.EventFailed}, eventTypes(events)) {
+			assert.Equal(t, "telco-finder", events[0].API)
+			assert.True(t, errors.Is(events[0].Err, utils.ErrRateLimited))
+		}
+		assert.Empty(t, subscriber.Events())
+	})
+}
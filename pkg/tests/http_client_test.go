@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/GlideApis/sdk-go/pkg/glide"
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingTransport struct {
+	calls int32
+	next  http.RoundTripper
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.calls, 1)
+	req.Header.Set("X-Test-Transport", "yes")
+	return t.next.RoundTrip(req)
+}
+
+func TestCustomHTTPClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Test-Transport") != "yes" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/oauth2/token":
+			WriteTokenResponse(w, "token", "telco-finder", 3600)
+		case "/telco-finder/v1/search":
+			w.Write([]byte(`{"subject":"tel:+555123456789","properties":{"operator_Id":"Test Operator"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	transport := &countingTransport{next: http.DefaultTransport}
+	settings := NewOfflineSettings(server.URL)
+	settings.HTTPClient = &http.Client{Transport: transport}
+	glideClient, err := glide.NewGlideClient(settings)
+	assert.NoError(t, err)
+
+	res, err := glideClient.TelcoFinder.LookupNumber("+555123456789", types.ApiConfig{})
+	assert.NoError(t, err)
+	assert.Equal(t, "Test Operator", res.Properties.OperatorID)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&transport.calls), "token and search calls should use the custom client")
+}
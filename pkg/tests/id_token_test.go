@@ -0,0 +1,211 @@
+package tests
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/GlideApis/sdk-go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// testIssuer publishes an OpenID configuration and JWKS and signs ID tokens
+// with its current key.
+type testIssuer struct {
+	mu  sync.Mutex
+	url string
+	key *ecdsa.PrivateKey
+	kid string
+	// alg, if set, restricts the published key to that algorithm.
+	alg string
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	return &testIssuer{key: key, kid: "key-1"}
+}
+
+// serve handles discovery and JWKS requests and reports whether it did.
+func (i *testIssuer) serve(w http.ResponseWriter, r *http.Request) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		json.NewEncoder(w).Encode(map[string]string{"issuer": i.url, "jwks_uri": i.url + "/jwks"})
+	case "/jwks":
+		pub := i.key.PublicKey
+		jwk := map[string]string{
+			"kty": "EC",
+			"crv": "P-256",
+			"kid": i.kid,
+			"use": "sig",
+			"x":   base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, 32))),
+		}
+		if i.alg != "" {
+			jwk["alg"] = i.alg
+		}
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{jwk}})
+	default:
+		return false
+	}
+	return true
+}
+
+// sign returns an ID token for test-client with claims added to defaults.
+func (i *testIssuer) sign(t *testing.T, claims map[string]any) string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	all := map[string]any{
+		"iss": i.url,
+		"sub": "user-1",
+		"aud": "test-client",
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		all[k] = v
+	}
+	token, err := utils.SignJWT(i.key, "ES256", i.kid, all)
+	assert.NoError(t, err)
+	return token
+}
+
+func (i *testIssuer) rotate(t *testing.T, kid string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.key, i.kid = key, kid
+}
+
+func TestIDTokenVerification(t *testing.T) {
+	issuer := newTestIssuer(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		issuer.serve(w, r)
+	}))
+	defer server.Close()
+	issuer.url = server.URL
+
+	verifier := utils.NewIDTokenVerifier(NewOfflineSettings(server.URL))
+	ctx := context.Background()
+
+	t.Run("returns typed claims of a valid token", func(t *testing.T) {
+		claims, err := verifier.Verify(ctx, issuer.sign(t, map[string]any{
+			"nonce":        "n-1",
+			"phone_number": "+555123456789",
+			"ext":          map[string]string{"operator": "Test Operator"},
+		}), "n-1")
+		assert.NoError(t, err)
+		assert.Equal(t, "user-1", claims.Subject)
+		assert.Equal(t, []string{"test-client"}, claims.Audience)
+		assert.Equal(t, "+555123456789", claims.PhoneNumber)
+		assert.Equal(t, "Test Operator", claims.Operator)
+	})
+
+	for name, tc := range map[string]struct {
+		claims map[string]any
+		nonce  string
+	}{
+		"wrong audience":  {claims: map[string]any{"aud": []string{"other-client"}}},
+		"wrong issuer":    {claims: map[string]any{"iss": "https://evil.example.com"}},
+		"expired":         {claims: map[string]any{"exp": time.Now().Add(-time.Hour).Unix()}},
+		"nonce mismatch":  {claims: map[string]any{"nonce": "n-1"}, nonce: "n-2"},
+		"missing nonce":   {nonce: "n-1"},
+		"foreign azp":     {claims: map[string]any{"aud": []string{"test-client", "x"}, "azp": "x"}},
+		"missing expires": {claims: map[string]any{"exp": nil}},
+	} {
+		t.Run("rejects "+name, func(t *testing.T) {
+			_, err := verifier.Verify(ctx, issuer.sign(t, tc.claims), tc.nonce)
+			assert.ErrorIs(t, err, utils.ErrInvalidToken)
+		})
+	}
+
+	t.Run("rejects a tampered signature", func(t *testing.T) {
+		token := issuer.sign(t, nil)
+		_, err := verifier.Verify(ctx, token[:len(token)-4]+"AAAA", "")
+		assert.ErrorIs(t, err, utils.ErrInvalidToken)
+		_, err = verifier.Verify(ctx, unsignedJWT(map[string]any{"iss": server.URL, "aud": "test-client"}), "")
+		assert.ErrorIs(t, err, utils.ErrInvalidToken)
+	})
+
+	t.Run("rejects an algorithm the key was not made for", func(t *testing.T) {
+		// a P-256 key also verifies ES384 signatures unless the curve is checked
+		issuer.mu.Lock()
+		token, err := utils.SignJWT(issuer.key, "ES384", issuer.kid, map[string]any{
+			"iss": server.URL,
+			"aud": "test-client",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+		issuer.mu.Unlock()
+		assert.NoError(t, err)
+		_, err = verifier.Verify(ctx, token, "")
+		assert.ErrorIs(t, err, utils.ErrInvalidToken)
+	})
+
+	t.Run("rejects keys published for another algorithm", func(t *testing.T) {
+		issuer.mu.Lock()
+		issuer.alg = "ES384"
+		issuer.mu.Unlock()
+		defer func() {
+			issuer.mu.Lock()
+			issuer.alg = ""
+			issuer.mu.Unlock()
+		}()
+		verifier := utils.NewIDTokenVerifier(NewOfflineSettings(server.URL))
+		_, err := verifier.Verify(ctx, issuer.sign(t, nil), "")
+		assert.ErrorIs(t, err, utils.ErrInvalidToken)
+	})
+
+	t.Run("waiting for the JWKS honours the context", func(t *testing.T) {
+		started, release := make(chan struct{}), make(chan struct{})
+		var once sync.Once
+		var slow *httptest.Server
+		slow = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/jwks" {
+				once.Do(func() { close(started) })
+				<-release
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"issuer": slow.URL, "jwks_uri": slow.URL + "/jwks"})
+		}))
+		defer slow.Close()
+		defer close(release)
+		keys := utils.NewKeySet(NewOfflineSettings(slow.URL), utils.NewDiscovery(NewOfflineSettings(slow.URL)))
+		go keys.Keys(context.Background(), "", "ES256")
+		<-started
+
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		_, err := keys.Keys(ctx, "", "ES256")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("reloads the JWKS for rotated keys", func(t *testing.T) {
+		verifier := utils.NewIDTokenVerifier(NewOfflineSettings(server.URL))
+		_, err := verifier.Verify(ctx, issuer.sign(t, nil), "")
+		assert.NoError(t, err)
+		issuer.rotate(t, "key-2")
+		_, err = verifier.Verify(ctx, issuer.sign(t, nil), "")
+		assert.NoError(t, err)
+	})
+}
+
+func TestGetOperatorDecodesBase64URL(t *testing.T) {
+	// "??>" encodes to "Pz8-" in base64url and "Pz8+" in standard base64.
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"ext":{"operator":"??>"}}`))
+	operator, err := utils.GetOperator(&types.Session{AccessToken: fmt.Sprintf("e30.%s.sig", payload)})
+	assert.NoError(t, err)
+	assert.Equal(t, "??>", operator)
+}
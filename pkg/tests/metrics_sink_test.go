@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/glide"
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/GlideApis/sdk-go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsSink(t *testing.T) {
+	t.Run("services report to the configured sink", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/oauth2/token":
+				WriteTokenResponse(w, "token", "magic-auth", 3600)
+			default:
+				w.Write([]byte(`{"type":"MAGIC","operatorId":"Test Operator"}`))
+			}
+		}))
+		defer server.Close()
+
+		sink := utils.NewMemoryMetricsSink()
+		settings := NewOfflineSettings(server.URL)
+		settings.Metrics = sink
+		glideClient, err := glide.NewGlideClient(settings)
+		assert.NoError(t, err)
+		_, err = glideClient.MagicAuth.StartAuth(types.MagicAuthStartProps{PhoneNumber: "+555123456789"}, types.ApiConfig{SessionIdentifier: "session-1"})
+		assert.NoError(t, err)
+
+		metrics := sink.Metrics()
+		if assert.Len(t, metrics, 2) {
+			assert.Equal(t, "Glide start", metrics[0].MetricName)
+			assert.Equal(t, "Glide verificationStartRes", metrics[1].MetricName)
+			assert.Equal(t, "Test Operator", metrics[1].Operator)
+			assert.Equal(t, "session-1", metrics[1].SessionId)
+			assert.Equal(t, "magic-auth", metrics[1].Api)
+		}
+	})
+
+	t.Run("HTTP sink does not block and delivers on flush", func(t *testing.T) {
+		release := make(chan struct{})
+		var mu sync.Mutex
+		var received []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			mu.Lock()
+			received = append(received, body["metricName"].(string))
+			mu.Unlock()
+		}))
+		defer server.Close()
+
+		sink := utils.NewHTTPMetricsSink(server.URL, utils.HTTPMetricsSinkOptions{FlushInterval: time.Hour})
+		start := time.Now()
+		for _, name := range []string{"one", "two", "three"} {
+			sink.Report(context.Background(), types.MetricInfo{MetricName: name, Timestamp: time.Now()})
+		}
+		assert.Less(t, time.Since(start), 100*time.Millisecond)
+
+		close(release)
+		assert.NoError(t, sink.Flush(context.Background()))
+		mu.Lock()
+		assert.ElementsMatch(t, []string{"one", "two", "three"}, received)
+		mu.Unlock()
+		assert.NoError(t, sink.Close(context.Background()))
+	})
+
+	t.Run("HTTP sink drops metrics when the queue is full or closed", func(t *testing.T) {
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		defer server.Close()
+
+		sink := utils.NewHTTPMetricsSink(server.URL, utils.HTTPMetricsSinkOptions{QueueSize: 2, BatchSize: 1})
+		for i := 0; i < 10; i++ {
+			sink.Report(context.Background(), types.MetricInfo{MetricName: "m", Timestamp: time.Now()})
+		}
+		// The worker holds at most one metric in flight plus two queued.
+		assert.GreaterOrEqual(t, sink.Dropped(), int64(7))
+
+		close(release)
+		assert.NoError(t, sink.Close(context.Background()))
+		dropped := sink.Dropped()
+		sink.Report(context.Background(), types.MetricInfo{MetricName: "late"})
+		assert.Equal(t, dropped+1, sink.Dropped())
+	})
+
+	t.Run("HTTP sink sends concurrently and gives up on a hanging collector", func(t *testing.T) {
+		hang := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-hang:
+			case <-r.Context().Done():
+			}
+		}))
+		defer server.Close()
+		defer close(hang)
+
+		sink := utils.NewHTTPMetricsSink(server.URL, utils.HTTPMetricsSinkOptions{
+			FlushInterval: time.Hour,
+			SendTimeout:   100 * time.Millisecond,
+			Retry:         &types.RetryPolicy{MaxAttempts: 1},
+		})
+		for i := 0; i < 5; i++ {
+			sink.Report(context.Background(), types.MetricInfo{MetricName: "m", Timestamp: time.Now()})
+		}
+		start := time.Now()
+		assert.NoError(t, sink.Flush(context.Background()))
+		assert.Less(t, time.Since(start), 400*time.Millisecond, "sends time out in parallel")
+
+		for i := 0; i < 5; i++ {
+			sink.Report(context.Background(), types.MetricInfo{MetricName: "m", Timestamp: time.Now()})
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		start = time.Now()
+		assert.ErrorIs(t, sink.Close(ctx), context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), 100*time.Millisecond)
+	})
+}
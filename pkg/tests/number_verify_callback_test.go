@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/GlideApis/sdk-go/pkg/glide"
+	"github.com/GlideApis/sdk-go/pkg/services"
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/GlideApis/sdk-go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNumberVerifyCallbackHandler(t *testing.T) {
+	var nonce string
+	issuer := newTestIssuer(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if issuer.serve(w, r) {
+			return
+		}
+		switch r.URL.Path {
+		case "/oauth2/token":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"access_token":"token","expires_in":3600,"scope":"openid","id_token":%q}`, issuer.sign(t, map[string]any{"nonce": nonce}))
+		case "/number-verification/verify":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			assert.Equal(t, "+555123456789", body["phoneNumber"])
+			w.Write([]byte(`{"devicePhoneNumberVerified":true}`))
+		}
+	}))
+	defer server.Close()
+	issuer.url = server.URL
+
+	glideClient, err := glide.NewGlideClient(NewOfflineSettings(server.URL))
+	assert.NoError(t, err)
+
+	var results []*services.NumberVerifyCallbackResult
+	var errs []error
+	handler := glideClient.NumberVerify.NewCallbackHandler(services.NumberVerifyCallbackOptions{
+		OnResult: func(w http.ResponseWriter, r *http.Request, result *services.NumberVerifyCallbackResult) {
+			results = append(results, result)
+		},
+		OnError: func(w http.ResponseWriter, r *http.Request, err error) {
+			errs = append(errs, err)
+		},
+	})
+
+	start := func(t *testing.T) url.Values {
+		authURL, err := handler.AuthURL(context.Background(), types.NumberVerifyAuthUrlInput{PhoneNumber: "+555123456789"})
+		assert.NoError(t, err)
+		parsed, err := url.Parse(authURL)
+		assert.NoError(t, err)
+		nonce = parsed.Query().Get("nonce")
+		return parsed.Query()
+	}
+	callback := func(query string) {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/callback?"+query, nil))
+	}
+
+	t.Run("exchanges the code and verifies the number", func(t *testing.T) {
+		results, errs = nil, nil
+		query := start(t)
+		callback("code=abc&state=" + query.Get("state"))
+		assert.Empty(t, errs)
+		if assert.Len(t, results, 1) {
+			assert.True(t, results[0].Result.DevicePhoneNumberVerified)
+			assert.Equal(t, query.Get("state"), results[0].AuthRequest.State)
+		}
+
+		callback("code=abc&state=" + query.Get("state"))
+		if assert.Len(t, errs, 1) {
+			assert.ErrorIs(t, errs[0], utils.ErrInvalidRequest, "a state can only be used once")
+		}
+	})
+
+	t.Run("reports error callbacks", func(t *testing.T) {
+		results, errs = nil, nil
+		query := start(t)
+		callback("error=access_denied&error_description=user+declined&state=" + query.Get("state"))
+		assert.Empty(t, results)
+		if assert.Len(t, errs, 1) {
+			assert.ErrorIs(t, errs[0], utils.ErrPermissionDenied)
+			assert.ErrorContains(t, errs[0], "user declined")
+		}
+	})
+
+	t.Run("default error response", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		glideClient.NumberVerify.NewCallbackHandler(services.NumberVerifyCallbackOptions{}).
+			ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/callback?code=abc&state=unknown", nil))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Equal(t, "Bad Request\n", recorder.Body.String(), "error details stay out of the response")
+	})
+}
@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/GlideApis/sdk-go/pkg/glide"
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/GlideApis/sdk-go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFunctionalOptions(t *testing.T) {
+	t.Run("builds a working client", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/oauth2/token":
+				WriteTokenResponse(w, "token", "telco-finder", 3600)
+			default:
+				w.Write([]byte(`{"subject":"tel:+555123456789","properties":{"operator_Id":"Test Operator"}}`))
+			}
+		}))
+		defer server.Close()
+
+		glideClient, err := glide.New(
+			glide.WithoutEnv(),
+			glide.WithCredentials("test-client", "test-secret"),
+			glide.WithBaseURLs(server.URL, server.URL),
+			glide.WithHTTPClient(server.Client()),
+			glide.WithRetry(nil),
+		)
+		assert.NoError(t, err)
+		assert.Nil(t, glideClient.Settings.Retry)
+		res, err := glideClient.TelcoFinder.LookupNumber("+555123456789", types.ApiConfig{})
+		assert.NoError(t, err)
+		assert.Equal(t, "Test Operator", res.Properties.OperatorID)
+	})
+
+	t.Run("explicit values override env, even when empty", func(t *testing.T) {
+		t.Setenv("GLIDE_CLIENT_ID", "env-client")
+		t.Setenv("GLIDE_CLIENT_SECRET", "env-secret")
+		glideClient, err := glide.New(glide.WithCredentials("explicit-client", ""))
+		assert.NoError(t, err)
+		assert.Equal(t, "explicit-client", glideClient.Settings.ClientID)
+		assert.Equal(t, "", glideClient.Settings.ClientSecret)
+	})
+
+	t.Run("WithoutEnv ignores env vars", func(t *testing.T) {
+		t.Setenv("GLIDE_CLIENT_ID", "env-client")
+		t.Setenv("GLIDE_API_BASE_URL", "https://env.example.com")
+		_, err := glide.New(glide.WithoutEnv())
+		assert.ErrorContains(t, err, "clientId is required")
+
+		glideClient, err := glide.New(glide.WithoutEnv(), glide.WithCredentials("client", "secret"))
+		assert.NoError(t, err)
+		assert.Equal(t, "https://api.gateway-x.io", glideClient.Settings.Internal.APIBaseURL)
+	})
+
+	t.Run("reports all configuration errors at once", func(t *testing.T) {
+		_, err := glide.New(
+			glide.WithoutEnv(),
+			glide.WithBaseURLs("", "ftp://api.example.com"),
+			glide.WithRedirectURI("/callback"),
+			glide.WithRetry(&types.RetryPolicy{MaxAttempts: 3, Jitter: 2}),
+		)
+		assert.True(t, errors.Is(err, utils.ErrConfiguration))
+		for _, msg := range []string{
+			"clientId is required",
+			"internal.authBaseUrl is unset",
+			"internal.apiBaseUrl must be an absolute http(s) URL",
+			"redirectUri must be an absolute URL",
+			"retry jitter must be between 0 and 1",
+		} {
+			assert.ErrorContains(t, err, msg)
+			assert.Equal(t, 1, strings.Count(err.Error(), msg), "reported once")
+		}
+	})
+
+	t.Run("accepts custom scheme redirect URIs", func(t *testing.T) {
+		for _, uri := range []string{"com.example.app:/oauth/callback", "myapp://callback", "https://app.example.com/callback"} {
+			_, err := glide.New(glide.WithoutEnv(), glide.WithCredentials("client", "secret"), glide.WithRedirectURI(uri))
+			assert.NoError(t, err, uri)
+		}
+	})
+}
@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/GlideApis/sdk-go/pkg/glide"
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/GlideApis/sdk-go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	var mu sync.Mutex
+	mints := map[string]int{}
+	var apiAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth2/token":
+			clientID, secret, _ := r.BasicAuth()
+			mu.Lock()
+			mints[clientID+":"+secret]++
+			mu.Unlock()
+			WriteTokenResponse(w, "token-"+clientID, "telco-finder", 3600)
+		default:
+			mu.Lock()
+			apiAuth = r.Header.Get("Authorization")
+			mu.Unlock()
+			w.Write([]byte(`{"subject":"tel:+555123456789","properties":{"operator_Id":"Test Operator"}}`))
+		}
+	}))
+	defer server.Close()
+	mintCount := func(creds string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return mints[creds]
+	}
+
+	var resolves int32
+	secrets := map[string]string{"unit-a": "secret-a", "unit-b": "secret-b"}
+	registry := glide.NewRegistry(types.GlideSdkSettings{
+		HTTPClient: server.Client(),
+		Internal:   types.InternalSettings{AuthBaseURL: server.URL, APIBaseURL: server.URL},
+	}, func(ctx context.Context, tenant string) (types.GlideSdkSettings, error) {
+		atomic.AddInt32(&resolves, 1)
+		secret, ok := secrets[tenant]
+		if !ok {
+			return types.GlideSdkSettings{}, errors.New("unknown tenant")
+		}
+		return types.GlideSdkSettings{ClientID: tenant, ClientSecret: secret}, nil
+	})
+	defer registry.Close(context.Background())
+	ctx := context.Background()
+
+	t.Run("creates each tenant's client once", func(t *testing.T) {
+		var wg sync.WaitGroup
+		clients := make([]*glide.GlideClient, 10)
+		for i := range clients {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				clients[i], _ = registry.Client(ctx, "unit-a")
+			}(i)
+		}
+		wg.Wait()
+		for _, client := range clients {
+			assert.Same(t, clients[0], client)
+		}
+		assert.Equal(t, int32(1), atomic.LoadInt32(&resolves))
+		assert.Equal(t, "unit-a", clients[0].Settings.ClientID)
+
+		b, err := registry.Client(ctx, "unit-b")
+		assert.NoError(t, err)
+		assert.Same(t, clients[0].Settings.HTTPClient, b.Settings.HTTPClient)
+		assert.Equal(t, clients[0].Settings.TokenStore, b.Settings.TokenStore)
+		assert.Equal(t, []string{"unit-a", "unit-b"}, registry.Tenants())
+	})
+
+	t.Run("does not cache resolver failures", func(t *testing.T) {
+		before := atomic.LoadInt32(&resolves)
+		_, err := registry.Client(ctx, "unknown")
+		assert.Error(t, err)
+		_, err = registry.Client(ctx, "unknown")
+		assert.Error(t, err)
+		assert.Equal(t, before+2, atomic.LoadInt32(&resolves))
+	})
+
+	t.Run("evicted tenants share stored tokens", func(t *testing.T) {
+		a, _ := registry.Client(ctx, "unit-a")
+		_, err := a.TelcoFinder.LookupNumber("+555123456789", types.ApiConfig{})
+		assert.NoError(t, err)
+
+		registry.Evict("unit-a")
+		again, err := registry.Client(ctx, "unit-a")
+		assert.NoError(t, err)
+		assert.NotSame(t, a, again)
+		_, err = again.TelcoFinder.LookupNumber("+555123456789", types.ApiConfig{})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, mintCount("unit-a:secret-a"))
+	})
+
+	t.Run("rotation mints with the new credentials", func(t *testing.T) {
+		err := registry.Rotate(ctx, "unit-a", types.GlideSdkSettings{ClientID: "unit-a", ClientSecret: "secret-a2"})
+		assert.NoError(t, err)
+		rotated, err := registry.Client(ctx, "unit-a")
+		assert.NoError(t, err)
+		assert.Equal(t, "secret-a2", rotated.Settings.ClientSecret)
+		_, err = rotated.TelcoFinder.LookupNumber("+555123456789", types.ApiConfig{})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, mintCount("unit-a:secret-a2"))
+	})
+
+	t.Run("provider-only tenants keep their own tokens", func(t *testing.T) {
+		providers := glide.NewRegistry(types.GlideSdkSettings{
+			HTTPClient: server.Client(),
+			Internal:   types.InternalSettings{AuthBaseURL: server.URL, APIBaseURL: server.URL},
+		}, func(ctx context.Context, tenant string) (types.GlideSdkSettings, error) {
+			return types.GlideSdkSettings{
+				Credentials: utils.StaticCredentials{ClientID: "provided-" + tenant, ClientSecret: "secret"},
+			}, nil
+		})
+		defer providers.Close(ctx)
+
+		for _, tenant := range []string{"x", "y"} {
+			client, err := providers.Client(ctx, tenant)
+			assert.NoError(t, err)
+			_, err = client.TelcoFinder.LookupNumber("+555123456789", types.ApiConfig{})
+			assert.NoError(t, err)
+			mu.Lock()
+			assert.Equal(t, "Bearer token-provided-"+tenant, apiAuth)
+			mu.Unlock()
+			assert.Equal(t, 1, mintCount("provided-"+tenant+":secret"))
+		}
+	})
+}
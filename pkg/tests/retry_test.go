@@ -0,0 +1,169 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/glide"
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/GlideApis/sdk-go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func fastRetryPolicy() *types.RetryPolicy {
+	return &types.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     10 * time.Millisecond,
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	t.Run("retries idempotent calls on 5xx", func(t *testing.T) {
+		var calls int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/oauth2/token":
+				WriteTokenResponse(w, "token", "telco-finder", 3600)
+			default:
+				if atomic.AddInt32(&calls, 1) < 3 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.Write([]byte(`{"subject":"tel:+555123456789","properties":{"operator_Id":"Test Operator"}}`))
+			}
+		}))
+		defer server.Close()
+
+		settings := NewOfflineSettings(server.URL)
+		settings.Retry = fastRetryPolicy()
+		glideClient, err := glide.NewGlideClient(settings)
+		assert.NoError(t, err)
+		res, err := glideClient.TelcoFinder.LookupNumber("+555123456789", types.ApiConfig{})
+		assert.NoError(t, err)
+		assert.Equal(t, "Test Operator", res.Properties.OperatorID)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("does not retry non-idempotent calls on 5xx", func(t *testing.T) {
+		var calls int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/oauth2/token":
+				WriteTokenResponse(w, "token", "magic-auth", 3600)
+			default:
+				atomic.AddInt32(&calls, 1)
+				w.WriteHeader(http.StatusBadGateway)
+			}
+		}))
+		defer server.Close()
+
+		settings := NewOfflineSettings(server.URL)
+		settings.Retry = fastRetryPolicy()
+		glideClient, err := glide.NewGlideClient(settings)
+		assert.NoError(t, err)
+		_, err = glideClient.MagicAuth.StartAuth(types.MagicAuthStartProps{PhoneNumber: "+555123456789"}, types.ApiConfig{})
+		assert.Error(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("honours Retry-After on 429", func(t *testing.T) {
+		var calls int32
+		var firstAt, secondAt time.Time
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				firstAt = time.Now()
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			secondAt = time.Now()
+			w.Write([]byte(`ok`))
+		}))
+		defer server.Close()
+
+		policy := fastRetryPolicy()
+		policy.MaxBackoff = 2 * time.Second
+		_, err := utils.FetchX(server.URL, utils.FetchXInput{Method: "POST", Retry: policy})
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+		assert.GreaterOrEqual(t, secondAt.Sub(firstAt), 900*time.Millisecond)
+	})
+
+	t.Run("returns a 429 whose Retry-After exceeds MaxBackoff", func(t *testing.T) {
+		var calls int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/oauth2/token":
+				WriteTokenResponse(w, "token", "telco-finder", 3600)
+			default:
+				atomic.AddInt32(&calls, 1)
+				w.Header().Set("Retry-After", "60")
+				w.WriteHeader(http.StatusTooManyRequests)
+			}
+		}))
+		defer server.Close()
+
+		settings := NewOfflineSettings(server.URL)
+		settings.Retry = fastRetryPolicy()
+		glideClient, err := glide.NewGlideClient(settings)
+		assert.NoError(t, err)
+		start := time.Now()
+		_, err = glideClient.TelcoFinder.LookupNumber("+555123456789", types.ApiConfig{})
+		assert.ErrorIs(t, err, utils.ErrRateLimited)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("returns a 429 whose Retry-After exceeds the deadline", func(t *testing.T) {
+		var calls int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer server.Close()
+
+		policy := fastRetryPolicy()
+		policy.MaxBackoff = 2 * time.Second
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+		_, err := utils.FetchXWithContext(ctx, server.URL, utils.FetchXInput{Method: "GET", Retry: policy})
+		var fetchErr *utils.FetchError
+		assert.ErrorAs(t, err, &fetchErr)
+		assert.NotErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("RetryableStatusCodes can exclude 429", func(t *testing.T) {
+		var calls int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer server.Close()
+
+		policy := fastRetryPolicy()
+		policy.RetryableStatusCodes = []int{http.StatusServiceUnavailable}
+		_, err := utils.FetchX(server.URL, utils.FetchXInput{Method: "GET", Retry: policy})
+		assert.Error(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("nil policy makes a single attempt", func(t *testing.T) {
+		var calls int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		_, err := utils.FetchX(server.URL, utils.FetchXInput{Method: "GET"})
+		assert.Error(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+}
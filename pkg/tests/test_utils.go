@@ -125,3 +125,23 @@ func MakeRawHttpRequestFollowRedirectChain(urlStr string) (*HttpResponse, error)
 		finalURL: resp.Request.URL.String(),
 	}, nil
 }
+
+// NewOfflineSettings returns settings pointing both auth and API base URLs at
+// baseURL, for tests that run against an httptest server.
+func NewOfflineSettings(baseURL string) types.GlideSdkSettings {
+	return types.GlideSdkSettings{
+		ClientID:     "test-client",
+		ClientSecret: "test-secret",
+		RedirectURI:  "https://example.com/callback",
+		Internal: types.InternalSettings{
+			AuthBaseURL: baseURL,
+			APIBaseURL:  baseURL,
+		},
+	}
+}
+
+// WriteTokenResponse writes a client credentials token response granting scope.
+func WriteTokenResponse(w http.ResponseWriter, accessToken, scope string, expiresIn int) {
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"access_token":%q,"token_type":"Bearer","expires_in":%d,"scope":%q}`, accessToken, expiresIn, scope)
+}
@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/glide"
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/GlideApis/sdk-go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenManager(t *testing.T) {
+	var mints int32
+	var expiresIn int32 = 3600
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth2/token":
+			atomic.AddInt32(&mints, 1)
+			r.ParseForm()
+			time.Sleep(20 * time.Millisecond)
+			WriteTokenResponse(w, "token-"+r.Form.Get("scope"), r.Form.Get("scope"), int(atomic.LoadInt32(&expiresIn)))
+		case "/telco-finder/v1/search":
+			w.Write([]byte(`{"subject":"tel:+555123456789","properties":{"operator_Id":"Test Operator"}}`))
+		case "/magic-auth/verification/start":
+			w.Write([]byte(`{"type":"MAGIC","authUrl":"https://example.com"}`))
+		}
+	}))
+	defer server.Close()
+
+	t.Run("deduplicates concurrent refreshes", func(t *testing.T) {
+		atomic.StoreInt32(&mints, 0)
+		manager := utils.NewTokenManager(NewOfflineSettings(server.URL))
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				session, err := manager.Token(context.Background(), "telco-finder")
+				assert.NoError(t, err)
+				assert.Equal(t, "token-telco-finder", session.AccessToken)
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, int32(1), atomic.LoadInt32(&mints))
+	})
+
+	t.Run("caches per scope set", func(t *testing.T) {
+		atomic.StoreInt32(&mints, 0)
+		manager := utils.NewTokenManager(NewOfflineSettings(server.URL))
+		_, err := manager.Token(context.Background(), "telco-finder", "magic-auth")
+		assert.NoError(t, err)
+		_, err = manager.Token(context.Background(), "magic-auth telco-finder")
+		assert.NoError(t, err)
+		_, err = manager.Token(context.Background(), "magic-auth")
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&mints))
+	})
+
+	t.Run("refreshes proactively before expiry", func(t *testing.T) {
+		atomic.StoreInt32(&mints, 0)
+		atomic.StoreInt32(&expiresIn, 4)
+		defer atomic.StoreInt32(&expiresIn, 3600)
+		manager := utils.NewTokenManager(NewOfflineSettings(server.URL))
+		first, err := manager.Token(context.Background(), "magic-auth")
+		assert.NoError(t, err)
+		// the refresh window of a 4s token is its last 2s
+		time.Sleep(2100 * time.Millisecond)
+		second, err := manager.Token(context.Background(), "magic-auth")
+		assert.NoError(t, err)
+		assert.Equal(t, first, second, "token close to expiry is still served while refreshing")
+		assert.Eventually(t, func() bool { return atomic.LoadInt32(&mints) == 2 }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("short-lived tokens are not refreshed on every call", func(t *testing.T) {
+		atomic.StoreInt32(&mints, 0)
+		atomic.StoreInt32(&expiresIn, 60)
+		defer atomic.StoreInt32(&expiresIn, 3600)
+		manager := utils.NewTokenManager(NewOfflineSettings(server.URL))
+		for i := 0; i < 5; i++ {
+			_, err := manager.Token(context.Background(), "magic-auth")
+			assert.NoError(t, err)
+			time.Sleep(30 * time.Millisecond)
+		}
+		assert.Equal(t, int32(1), atomic.LoadInt32(&mints))
+	})
+
+	t.Run("logs failed background refreshes", func(t *testing.T) {
+		var minted int32
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&minted, 1) > 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			WriteTokenResponse(w, "token", "magic-auth", 4)
+		}))
+		defer failing.Close()
+		var buf syncBuffer
+		settings := NewOfflineSettings(failing.URL)
+		settings.Logger = slog.New(slog.NewJSONHandler(&buf, nil))
+		manager := utils.NewTokenManager(settings)
+		_, err := manager.Token(context.Background(), "magic-auth")
+		assert.NoError(t, err)
+		time.Sleep(2100 * time.Millisecond)
+		_, err = manager.Token(context.Background(), "magic-auth")
+		assert.NoError(t, err, "the cached token is still served")
+		assert.Eventually(t, func() bool {
+			return strings.Contains(buf.String(), "Failed to refresh token ahead of expiry")
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("invalidation supersedes an in-flight mint", func(t *testing.T) {
+		atomic.StoreInt32(&mints, 0)
+		settings := NewOfflineSettings(server.URL)
+		store := utils.NewMemoryTokenStore()
+		settings.TokenStore = store
+		manager := utils.NewTokenManager(settings)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, err := manager.Token(context.Background(), "magic-auth")
+			assert.NoError(t, err)
+		}()
+		assert.Eventually(t, func() bool { return atomic.LoadInt32(&mints) == 1 }, time.Second, time.Millisecond)
+		manager.InvalidateAll(context.Background())
+		<-done
+
+		stored, err := store.Get(context.Background(), types.TokenKey{ClientID: settings.ClientID, Scope: "magic-auth"})
+		assert.NoError(t, err)
+		assert.Nil(t, stored)
+		_, err = manager.Token(context.Background(), "magic-auth")
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&mints))
+	})
+
+	t.Run("shared by all services of a GlideClient", func(t *testing.T) {
+		atomic.StoreInt32(&mints, 0)
+		glideClient, err := glide.NewGlideClient(NewOfflineSettings(server.URL))
+		assert.NoError(t, err)
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				_, err := glideClient.TelcoFinder.LookupNumber("+555123456789", types.ApiConfig{})
+				assert.NoError(t, err)
+			}()
+			go func() {
+				defer wg.Done()
+				_, err := glideClient.MagicAuth.StartAuth(types.MagicAuthStartProps{PhoneNumber: "+555123456789"}, types.ApiConfig{})
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, int32(2), atomic.LoadInt32(&mints), "one mint per scope")
+	})
+}
+
+// syncBuffer is a bytes.Buffer safe for logging from background goroutines.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
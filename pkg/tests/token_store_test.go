@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/GlideApis/sdk-go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenStore(t *testing.T) {
+	var mints int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&mints, 1)
+		r.ParseForm()
+		WriteTokenResponse(w, "token", r.Form.Get("scope"), 3600)
+	}))
+	defer server.Close()
+
+	stores := map[string]func(t *testing.T) types.TokenStore{
+		"memory": func(t *testing.T) types.TokenStore { return utils.NewMemoryTokenStore() },
+		"file": func(t *testing.T) types.TokenStore {
+			return utils.NewFileTokenStore(filepath.Join(t.TempDir(), "tokens.json"))
+		},
+	}
+	for name, newStore := range stores {
+		t.Run(name+" store round trip", func(t *testing.T) {
+			ctx := context.Background()
+			store := newStore(t)
+			key := types.TokenKey{ClientID: "client", Scope: "magic-auth"}
+			session, err := store.Get(ctx, key)
+			assert.NoError(t, err)
+			assert.Nil(t, session)
+
+			want := &types.Session{AccessToken: "abc", ExpiresAt: 42, Scopes: []string{"magic-auth"}}
+			assert.NoError(t, store.Put(ctx, key, want))
+			session, err = store.Get(ctx, key)
+			assert.NoError(t, err)
+			assert.Equal(t, want, session)
+
+			assert.NoError(t, store.Delete(ctx, key))
+			session, err = store.Get(ctx, key)
+			assert.NoError(t, err)
+			assert.Nil(t, session)
+		})
+
+		t.Run(name+" store shares tokens between managers", func(t *testing.T) {
+			atomic.StoreInt32(&mints, 0)
+			settings := NewOfflineSettings(server.URL)
+			settings.TokenStore = newStore(t)
+			first := utils.NewTokenManager(settings)
+			second := utils.NewTokenManager(settings)
+
+			a, err := first.Token(context.Background(), "telco-finder")
+			assert.NoError(t, err)
+			b, err := second.Token(context.Background(), "telco-finder")
+			assert.NoError(t, err)
+			assert.Equal(t, a.AccessToken, b.AccessToken)
+			assert.Equal(t, int32(1), atomic.LoadInt32(&mints))
+		})
+	}
+
+	t.Run("file stores on the same path keep concurrent writes", func(t *testing.T) {
+		// separate instances do not share a mutex, like separate processes
+		path := filepath.Join(t.TempDir(), "tokens.json")
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				key := types.TokenKey{ClientID: "client", Scope: fmt.Sprintf("scope-%d", i)}
+				assert.NoError(t, utils.NewFileTokenStore(path).Put(context.Background(), key, &types.Session{AccessToken: key.Scope}))
+			}(i)
+		}
+		wg.Wait()
+
+		store := utils.NewFileTokenStore(path)
+		for i := 0; i < 20; i++ {
+			session, err := store.Get(context.Background(), types.TokenKey{ClientID: "client", Scope: fmt.Sprintf("scope-%d", i)})
+			assert.NoError(t, err)
+			if assert.NotNil(t, session) {
+				assert.Equal(t, fmt.Sprintf("scope-%d", i), session.AccessToken)
+			}
+		}
+	})
+
+	t.Run("expired stored tokens are replaced", func(t *testing.T) {
+		atomic.StoreInt32(&mints, 0)
+		settings := NewOfflineSettings(server.URL)
+		store := utils.NewMemoryTokenStore()
+		settings.TokenStore = store
+		key := types.TokenKey{ClientID: settings.ClientID, Scope: "magic-auth"}
+		store.Put(context.Background(), key, &types.Session{AccessToken: "stale", ExpiresAt: time.Now().Unix() - 1})
+
+		session, err := utils.NewTokenManager(settings).Token(context.Background(), "magic-auth")
+		assert.NoError(t, err)
+		assert.Equal(t, "token", session.AccessToken)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&mints))
+		stored, _ := store.Get(context.Background(), key)
+		assert.Equal(t, "token", stored.AccessToken)
+	})
+}
@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/GlideApis/sdk-go/pkg/glide"
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func spanAttr(span sdktrace.ReadOnlySpan, key string) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if string(kv.Key) == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracing(t *testing.T) {
+	var mu sync.Mutex
+	traceparents := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		traceparents[r.URL.Path] = r.Header.Get("traceparent")
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/oauth2/token":
+			WriteTokenResponse(w, "token", "telco-finder", 3600)
+		case "/telco-finder/v1/search":
+			w.Write([]byte(`{"subject":"tel:+555123456789","properties":{"operator_Id":"Test Operator"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"status":404,"code":"NOT_FOUND","message":"no such resource"}`))
+		}
+	}))
+	defer server.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	defer provider.Shutdown(context.Background())
+
+	settings := NewOfflineSettings(server.URL)
+	settings.TracerProvider = provider
+	glideClient, err := glide.NewGlideClient(settings)
+	assert.NoError(t, err)
+
+	t.Run("operation span with token and HTTP children", func(t *testing.T) {
+		_, err := glideClient.TelcoFinder.LookupNumber("+555123456789", types.ApiConfig{})
+		assert.NoError(t, err)
+
+		spans := map[string]sdktrace.ReadOnlySpan{}
+		var httpSpans []sdktrace.ReadOnlySpan
+		for _, span := range recorder.Ended() {
+			if span.Name() == "POST" {
+				httpSpans = append(httpSpans, span)
+				continue
+			}
+			spans[span.Name()] = span
+		}
+		op, ok := spans["telco-finder.search"]
+		if !assert.True(t, ok, "missing operation span") {
+			return
+		}
+		assert.Equal(t, "telco-finder", spanAttr(op, "glide.api").AsString())
+		assert.Equal(t, "Test Operator", spanAttr(op, "glide.operator").AsString())
+
+		token, ok := spans["oauth2.token"]
+		if assert.True(t, ok, "missing token span") {
+			assert.Equal(t, op.SpanContext().SpanID(), token.Parent().SpanID())
+		}
+
+		assert.Len(t, httpSpans, 2)
+		for _, span := range httpSpans {
+			assert.Equal(t, op.SpanContext().TraceID(), span.SpanContext().TraceID())
+			assert.Equal(t, int64(200), spanAttr(span, "http.response.status_code").AsInt64())
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		for _, path := range []string{"/oauth2/token", "/telco-finder/v1/search"} {
+			assert.Contains(t, traceparents[path], op.SpanContext().TraceID().String(), path)
+		}
+	})
+
+	t.Run("failures set error status and HTTP status", func(t *testing.T) {
+		_, err := glideClient.TelcoFinder.NetworkIdForNumber("+555123456789", types.ApiConfig{})
+		assert.Error(t, err)
+
+		for _, span := range recorder.Ended() {
+			if span.Name() != "telco-finder.resolve-network-id" {
+				continue
+			}
+			assert.Equal(t, codes.Error, span.Status().Code)
+			assert.Equal(t, int64(404), spanAttr(span, "http.response.status_code").AsInt64())
+			assert.NotContains(t, span.Status().Description, "555123456789")
+			return
+		}
+		t.Fatal("missing operation span")
+	})
+}
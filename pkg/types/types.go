@@ -1,14 +1,267 @@
 package types
 
-import "time"
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
 
 // GlideSdkSettings represents the settings for the Glide SDK
 type GlideSdkSettings struct {
 	ClientID     string
 	ClientSecret string
 	RedirectURI  string
-	UseEnv       bool
-	Internal     InternalSettings
+	// UseEnv fills unset fields from GLIDE_* env vars and reports metrics to
+	// REPORT_METRIC_URL. When false the client does not read the environment.
+	UseEnv bool
+	// UseDiscovery loads the authorization server's endpoints from
+	// Internal.AuthBaseURL + "/.well-known/openid-configuration" instead of
+	// using the default paths under Internal.AuthBaseURL. The default paths
+	// are used for any endpoint the document does not advertise, or while it
+	// cannot be loaded.
+	UseDiscovery bool
+	// DiscoveryRefreshInterval is how often the discovery document is
+	// reloaded. It defaults to one hour.
+	DiscoveryRefreshInterval time.Duration
+	// Endpoints resolves the authorization server's endpoints. GlideClient
+	// shares one discovery cache across its services when this is nil.
+	Endpoints EndpointSource
+	Internal  InternalSettings
+	// Credentials supplies the client ID and secret for every token request,
+	// so that rotated secrets are picked up without rebuilding the client.
+	// When nil ClientID and ClientSecret are used.
+	Credentials CredentialsProvider
+	// ClientAuth selects how the client authenticates to the authorization
+	// server. The zero value uses HTTP Basic with the client secret.
+	ClientAuth ClientAuth
+	// HTTPClient is shared by every service, token endpoint and the metric
+	// reporter. Set its Transport to customise proxies, TLS or pooling.
+	// When nil a package-wide default client is used.
+	HTTPClient *http.Client
+	// Retry controls how failed API and token calls are retried.
+	Retry *RetryPolicy
+	// Logger receives the SDK's structured logs and applies its own level.
+	// When nil logs are written to stderr at Internal.LogLevel.
+	Logger *slog.Logger
+	// DisableLogRedaction logs tokens, phone numbers, emails and KYC fields
+	// in clear text. Only use this for local debugging.
+	DisableLogRedaction bool
+	// TokenSource supplies client credentials tokens. GlideClient shares one
+	// TokenManager across all of its services when this is nil.
+	TokenSource TokenSource
+	// TokenStore shares minted tokens across processes. When nil tokens are
+	// only cached in memory.
+	TokenStore TokenStore
+	// Metrics receives funnel metrics. GlideClient posts them to
+	// REPORT_METRIC_URL in the background when set and drops them otherwise.
+	Metrics MetricsSink
+	// TracerProvider creates the spans of SDK operations and their token and
+	// HTTP calls. When nil the global OpenTelemetry provider is used.
+	TracerProvider trace.TracerProvider
+	// Collector receives request, error and token refresh metrics for
+	// monitoring. GlideClient uses an in-memory collector when this is nil.
+	Collector MetricsCollector
+	// IDTokenVerifier verifies ID tokens against the issuer's JWKS.
+	// GlideClient shares one verifier across its services when this is nil.
+	IDTokenVerifier IDTokenVerifier
+	// Observer receives verification lifecycle events. GlideClient also
+	// delivers them to observers added with Subscribe.
+	Observer Observer
+	// CIBAMode is the token delivery mode registered for the client: poll
+	// (the default), ping or push. In ping and push modes SIM swap and KYC
+	// match wait for a notification instead of polling the token endpoint.
+	CIBAMode CIBAMode
+	// CIBANotifier routes ping and push notifications to the waiting user
+	// clients. GlideClient creates one when this is nil; serve its
+	// notifications with GlideClient.CIBANotificationHandler.
+	CIBANotifier CIBANotifier
+}
+
+// EventType identifies a verification lifecycle event.
+type EventType string
+
+const (
+	EventAuthStarted      EventType = "auth_started"
+	EventOperatorResolved EventType = "operator_resolved"
+	EventConsentRequired  EventType = "consent_required"
+	EventVerified         EventType = "verified"
+	EventUnverified       EventType = "unverified"
+	EventFailed           EventType = "failed"
+)
+
+// Event describes a step in a verification flow.
+type Event struct {
+	Type EventType
+	// API and Operation name the call raising the event, e.g. "magic-auth"
+	// and "verify".
+	API       string
+	Operation string
+	// SessionID is the ApiConfig.SessionIdentifier of the call, if any.
+	SessionID string
+	// Operator is the operator serving the user, when known.
+	Operator string
+	// ConsentURL is where the user grants consent, for EventConsentRequired.
+	ConsentURL string
+	// Err is the failure, for EventFailed.
+	Err  error
+	Time time.Time
+}
+
+// Observer receives verification lifecycle events. OnEvent is called
+// synchronously on the request path, so it must return quickly and be safe
+// for concurrent use.
+type Observer interface {
+	OnEvent(ctx context.Context, event Event)
+}
+
+// ObserverFunc adapts a function to the Observer interface.
+type ObserverFunc func(ctx context.Context, event Event)
+
+func (f ObserverFunc) OnEvent(ctx context.Context, event Event) {
+	f(ctx, event)
+}
+
+// MetricsCollector receives client-side metrics of HTTP calls and token
+// refreshes, e.g. to export them with prometheus/client_golang. Methods are
+// called on the request path and must be fast and safe for concurrent use.
+type MetricsCollector interface {
+	// ObserveRequest records one HTTP attempt. Status is 0 when no response
+	// was received.
+	ObserveRequest(method, endpoint string, status int, duration time.Duration)
+	// ObserveError counts a failed HTTP attempt by error type, such as
+	// "rate_limited", "upstream" or "timeout".
+	ObserveError(method, endpoint, errorType string)
+	// ObserveTokenRefresh counts a client credentials token request.
+	ObserveTokenRefresh(scope string, err error)
+}
+
+// MetricsSink receives funnel metrics from the services. Report is called on
+// the request path and must not block; implementations must be safe for
+// concurrent use.
+type MetricsSink interface {
+	Report(ctx context.Context, metric MetricInfo)
+	// Flush delivers buffered metrics, waiting until done or ctx expires.
+	Flush(ctx context.Context) error
+	// Close flushes buffered metrics and stops accepting new ones.
+	Close(ctx context.Context) error
+}
+
+// Credentials are the OAuth client credentials used at the token endpoint.
+type Credentials struct {
+	ClientID     string
+	ClientSecret string
+}
+
+// CredentialsProvider returns the current client credentials. It is called
+// for every token request, so implementations should be cheap and must be
+// safe for concurrent use.
+type CredentialsProvider interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// Endpoints are the authorization server endpoints used by the SDK, named as
+// in the OpenID Provider configuration.
+type Endpoints struct {
+	Issuer                            string `json:"issuer"`
+	AuthorizationEndpoint             string `json:"authorization_endpoint"`
+	TokenEndpoint                     string `json:"token_endpoint"`
+	BackchannelAuthenticationEndpoint string `json:"backchannel_authentication_endpoint"`
+	JWKSURI                           string `json:"jwks_uri"`
+}
+
+// EndpointSource resolves the authorization server endpoints. Endpoints
+// must always return usable values, falling back to defaults as needed.
+type EndpointSource interface {
+	Endpoints(ctx context.Context) Endpoints
+}
+
+// ClientAuthMethod is an OAuth token endpoint authentication method.
+type ClientAuthMethod string
+
+const (
+	// ClientSecretBasic sends the client ID and secret with HTTP Basic.
+	ClientSecretBasic ClientAuthMethod = "client_secret_basic"
+	// PrivateKeyJWT sends a client assertion signed with a private key
+	// (RFC 7523).
+	PrivateKeyJWT ClientAuthMethod = "private_key_jwt"
+	// TLSClientAuth authenticates with the TLS client certificate and sends
+	// only the client ID (RFC 8705).
+	TLSClientAuth ClientAuthMethod = "tls_client_auth"
+)
+
+// ClientAuth configures client authentication to the authorization server.
+type ClientAuth struct {
+	// Method defaults to ClientSecretBasic.
+	Method ClientAuthMethod
+	// PrivateKey signs client assertions for PrivateKeyJWT. It must be an
+	// *rsa.PrivateKey or *ecdsa.PrivateKey.
+	PrivateKey crypto.Signer
+	// KeyID is sent as the assertion's kid header when set.
+	KeyID string
+	// Algorithm overrides the signing algorithm, e.g. PS256. It defaults to
+	// RS256 for RSA keys and ES256, ES384 or ES512 for EC keys by curve.
+	Algorithm string
+	// Audience of client assertions. It defaults to the token endpoint URL,
+	// as OpenID Connect Core section 9 recommends, and is used for
+	// backchannel requests as well. Set it to the issuer identifier for
+	// servers that only accept that.
+	Audience string
+	// AssertionLifetime defaults to one minute.
+	AssertionLifetime time.Duration
+	// Certificate is presented on every connection for TLSClientAuth. When
+	// nil HTTPClient must already be configured with a client certificate.
+	Certificate *tls.Certificate
+}
+
+// TokenSource supplies client credentials access tokens for a set of scopes.
+type TokenSource interface {
+	Token(ctx context.Context, scopes ...string) (*Session, error)
+}
+
+// TokenKey identifies a cached token by client and normalised scope set.
+type TokenKey struct {
+	ClientID string
+	Scope    string
+}
+
+// String returns the key as a single string, e.g. for use as a Redis key.
+func (k TokenKey) String() string {
+	return k.ClientID + "|" + k.Scope
+}
+
+// TokenStore persists client credentials tokens so that they can be shared
+// between processes. Get returns a nil session and nil error when no token
+// is stored for key. Implementations must be safe for concurrent use.
+type TokenStore interface {
+	Get(ctx context.Context, key TokenKey) (*Session, error)
+	Put(ctx context.Context, key TokenKey, session *Session) error
+	Delete(ctx context.Context, key TokenKey) error
+}
+
+// RetryPolicy controls retries of transient failures (connection errors,
+// 429 and 5xx responses). Non-idempotent calls are only retried when the
+// server explicitly rejected them with 429.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts; 1 or less disables retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts. A Retry-After longer than
+	// MaxBackoff, or than the time left before the context's deadline, ends
+	// the retries and the 429 or 503 is returned.
+	MaxBackoff time.Duration
+	// Multiplier grows the delay after every attempt. Defaults to 2.
+	Multiplier float64
+	// Jitter randomises each delay by up to this fraction (0-1).
+	Jitter float64
+	// RetryableStatusCodes overrides the status codes that are retried.
+	// Calls that are not idempotent are only retried on 429, if listed.
+	RetryableStatusCodes []int
 }
 
 // InternalSettings represents internal settings for the SDK
@@ -20,9 +273,12 @@ type InternalSettings struct {
 
 // Session represents an authentication session
 type Session struct {
-	AccessToken string
-	ExpiresAt   int64
-	Scopes      []string
+	AccessToken string   `json:"accessToken"`
+	ExpiresAt   int64    `json:"expiresAt"`
+	Scopes      []string `json:"scopes"`
+	// IDToken is the OpenID Connect ID token returned with the access token,
+	// if any. Verify it with an IDTokenVerifier before trusting its claims.
+	IDToken string `json:"idToken,omitempty"`
 }
 
 // ApiConfig represents the configuration for API calls
@@ -114,6 +370,33 @@ type NumberVerifyAuthUrlInput struct {
 	State        *string `json:"state"`
 	UseDevNumber string  `json:"useDevNumber,omitempty"`
 	PrintCode    bool    `json:"printCode,omitempty"`
+	// DisablePKCE omits the code challenge from GetAuthRequest for
+	// authorization servers that do not support PKCE.
+	DisablePKCE bool `json:"disablePkce,omitempty"`
+	// PhoneNumber is kept with the request for the callback handler to
+	// verify. It is not sent to the authorization server.
+	PhoneNumber string `json:"phoneNumber,omitempty"`
+}
+
+// NumberVerifyAuthRequest is an authorization request built by
+// GetAuthRequest. Keep it with the user's session, e.g. in a signed cookie,
+// and pass it to For with the code and state of the redirect.
+type NumberVerifyAuthRequest struct {
+	URL          string `json:"url"`
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"codeVerifier,omitempty"`
+	PhoneNumber  string `json:"phoneNumber,omitempty"`
+}
+
+// AuthRequestStore keeps pending authorization requests by state between
+// the redirect to the authorization server and the callback, possibly on
+// another instance. Implementations must be safe for concurrent use.
+type AuthRequestStore interface {
+	Put(ctx context.Context, req *NumberVerifyAuthRequest) error
+	// Take returns and removes the request with state. It returns a nil
+	// request and nil error when there is none.
+	Take(ctx context.Context, state string) (*NumberVerifyAuthRequest, error)
 }
 
 type NumberVerifyResponse struct {
@@ -123,6 +406,110 @@ type NumberVerifyResponse struct {
 type NumberVerifyClientForParams struct {
 	Code        string
 	PhoneNumber *string
+	// AuthRequest is the request the code was issued for. When set, State
+	// must match its state, its code verifier is sent and the nonce of the
+	// returned id_token is checked.
+	AuthRequest *NumberVerifyAuthRequest
+	// State is the state parameter of the redirect.
+	State string
+}
+
+// CIBAStatus is the outcome of one poll of a backchannel authentication
+// request, named after the token endpoint's error codes.
+type CIBAStatus string
+
+const (
+	CIBAPending  CIBAStatus = "authorization_pending"
+	CIBASlowDown CIBAStatus = "slow_down"
+	CIBAApproved CIBAStatus = "approved"
+	CIBADenied   CIBAStatus = "access_denied"
+	CIBAExpired  CIBAStatus = "expired_token"
+	CIBAFailed   CIBAStatus = "failed"
+	// CIBAUnavailable means the poll failed with a 5xx, a 429 or a
+	// connection error; polling continues until the request expires.
+	CIBAUnavailable CIBAStatus = "temporarily_unavailable"
+)
+
+// CIBAProgress describes a poll made while waiting for the user to approve
+// a backchannel authentication request.
+type CIBAProgress struct {
+	// API is "sim-swap" or "kyc-match".
+	API string
+	// Attempt counts polls, starting at 1.
+	Attempt int
+	Status  CIBAStatus
+	// Interval is the wait before the next poll while the request is
+	// pending, including any slow_down increase.
+	Interval time.Duration
+	// ExpiresAt is when the request expires, or zero if the server did not
+	// say.
+	ExpiresAt time.Time
+}
+
+// CIBAMode is a CIBA token delivery mode.
+type CIBAMode string
+
+const (
+	CIBAPoll CIBAMode = "poll"
+	CIBAPing CIBAMode = "ping"
+	CIBAPush CIBAMode = "push"
+)
+
+// CIBANotification is the body of a ping or push notification sent to the
+// client notification endpoint. Ping notifications only carry AuthReqID;
+// push notifications also carry the tokens, or an error.
+type CIBANotification struct {
+	AuthReqID        string `json:"auth_req_id"`
+	AccessToken      string `json:"access_token,omitempty"`
+	TokenType        string `json:"token_type,omitempty"`
+	ExpiresIn        int64  `json:"expires_in,omitempty"`
+	IDToken          string `json:"id_token,omitempty"`
+	Error            string `json:"error,omitempty"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// CIBANotifier routes notifications by the client_notification_token sent
+// with the backchannel authentication request. Implementations must be safe
+// for concurrent use.
+type CIBANotifier interface {
+	// Register delivers notifications bearing token to deliver until
+	// unregister is called.
+	Register(token string, deliver func(ctx context.Context, notification CIBANotification) error) (unregister func())
+	// Notify delivers notification to the receiver registered for token. It
+	// fails when no receiver is registered, so that unauthenticated
+	// notifications are rejected.
+	Notify(ctx context.Context, token string, notification CIBANotification) error
+}
+
+// PendingConsent is a SIM swap or KYC match backchannel authentication
+// request awaiting the user's consent, with what another instance needs to
+// complete it.
+type PendingConsent struct {
+	// State identifies the consent when the user returns.
+	State      string        `json:"state"`
+	API        string        `json:"api"`
+	AuthReqID  string        `json:"authReqId"`
+	ConsentURL string        `json:"consentUrl,omitempty"`
+	Interval   time.Duration `json:"interval"`
+	ExpiresAt  time.Time     `json:"expiresAt"`
+	// PhoneNumber, IPAddress or UserID identifies the user.
+	PhoneNumber string `json:"phoneNumber,omitempty"`
+	IPAddress   string `json:"ipAddress,omitempty"`
+	UserID      string `json:"userId,omitempty"`
+}
+
+// ConsentStore keeps pending consents by state until the user returns. Take
+// must remove the consent, so that a state can only be used once, and return
+// nil for unknown or expired states.
+type ConsentStore interface {
+	Put(ctx context.Context, consent *PendingConsent) error
+	Take(ctx context.Context, state string) (*PendingConsent, error)
+}
+
+// CIBAPollOptions configures PollAndWaitForSessionWithOptions.
+type CIBAPollOptions struct {
+	// OnProgress is called synchronously after every poll.
+	OnProgress func(CIBAProgress)
 }
 
 // sim swap
@@ -155,6 +542,27 @@ type TokenData struct {
 	} `json:"ext"`
 }
 
+// IDTokenClaims are the verified claims of an OpenID Connect ID token.
+type IDTokenClaims struct {
+	Issuer      string
+	Subject     string
+	Audience    []string
+	ExpiresAt   time.Time
+	IssuedAt    time.Time
+	Nonce       string
+	PhoneNumber string
+	// Operator is the ext.operator claim set by Glide.
+	Operator string
+	// Raw holds every claim of the token.
+	Raw map[string]any
+}
+
+// IDTokenVerifier verifies the signature and standard claims of ID tokens
+// issued to the client. nonce is checked when not empty.
+type IDTokenVerifier interface {
+	Verify(ctx context.Context, idToken, nonce string) (*IDTokenClaims, error)
+}
+
 // Add LogLevel type if not already defined
 type LogLevel int
 
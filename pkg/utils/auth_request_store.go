@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/types"
+)
+
+// DefaultAuthRequestTTL is how long a MemoryAuthRequestStore keeps pending
+// authorization requests when no TTL is given.
+const DefaultAuthRequestTTL = 10 * time.Minute
+
+// MemoryAuthRequestStore keeps pending authorization requests in process
+// memory and forgets them after a TTL. Use a shared store, e.g. backed by
+// Redis, when callbacks may reach another instance.
+type MemoryAuthRequestStore struct {
+	ttl time.Duration
+
+	mu       sync.Mutex
+	requests map[string]pendingAuthRequest
+}
+
+type pendingAuthRequest struct {
+	req       types.NumberVerifyAuthRequest
+	expiresAt time.Time
+}
+
+// NewMemoryAuthRequestStore creates an empty store keeping requests for ttl,
+// or DefaultAuthRequestTTL when ttl is not positive.
+func NewMemoryAuthRequestStore(ttl time.Duration) *MemoryAuthRequestStore {
+	if ttl <= 0 {
+		ttl = DefaultAuthRequestTTL
+	}
+	return &MemoryAuthRequestStore{ttl: ttl, requests: map[string]pendingAuthRequest{}}
+}
+
+func (s *MemoryAuthRequestStore) Put(ctx context.Context, req *types.NumberVerifyAuthRequest) error {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for state, pending := range s.requests {
+		if now.After(pending.expiresAt) {
+			delete(s.requests, state)
+		}
+	}
+	s.requests[req.State] = pendingAuthRequest{req: *req, expiresAt: now.Add(s.ttl)}
+	return nil
+}
+
+func (s *MemoryAuthRequestStore) Take(ctx context.Context, state string) (*types.NumberVerifyAuthRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pending, ok := s.requests[state]
+	if !ok {
+		return nil, nil
+	}
+	delete(s.requests, state)
+	if time.Now().After(pending.expiresAt) {
+		return nil, nil
+	}
+	return &pending.req, nil
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/GlideApis/sdk-go/pkg/types"
+)
+
+// maxNotificationSize bounds the body of a CIBA notification.
+const maxNotificationSize = 64 << 10
+
+// CIBANotifications is an in-memory types.CIBANotifier. It only reaches user
+// clients of the current process; route notifications to the instance that
+// started the request, or use a shared implementation, when running several.
+type CIBANotifications struct {
+	mu        sync.Mutex
+	receivers map[[sha256.Size]byte]func(context.Context, types.CIBANotification) error
+}
+
+// NewCIBANotifications creates an empty CIBANotifications.
+func NewCIBANotifications() *CIBANotifications {
+	return &CIBANotifications{receivers: map[[sha256.Size]byte]func(context.Context, types.CIBANotification) error{}}
+}
+
+func (n *CIBANotifications) Register(token string, deliver func(context.Context, types.CIBANotification) error) func() {
+	// Keyed by digest so that lookups do not depend on the token's bytes.
+	key := sha256.Sum256([]byte(token))
+	n.mu.Lock()
+	n.receivers[key] = deliver
+	n.mu.Unlock()
+	return func() {
+		n.mu.Lock()
+		delete(n.receivers, key)
+		n.mu.Unlock()
+	}
+}
+
+func (n *CIBANotifications) Notify(ctx context.Context, token string, notification types.CIBANotification) error {
+	n.mu.Lock()
+	deliver := n.receivers[sha256.Sum256([]byte(token))]
+	n.mu.Unlock()
+	if token == "" || deliver == nil {
+		return &GlideError{Kind: ErrUnauthorized, API: "ciba", Operation: "notification", Message: "unknown client notification token"}
+	}
+	return deliver(ctx, notification)
+}
+
+// CIBANotificationHandler returns the handler of the client notification
+// endpoint. It authenticates notifications by their bearer token, the
+// client_notification_token of the request, and hands them to notifier. It
+// answers 204 when delivered, 401 for unknown tokens and 400 otherwise.
+func CIBANotificationHandler(notifier types.CIBANotifier) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !strings.EqualFold(scheme, "Bearer") || token == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "missing bearer token", http.StatusUnauthorized)
+			return
+		}
+		var notification types.CIBANotification
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxNotificationSize)).Decode(&notification); err != nil || notification.AuthReqID == "" {
+			http.Error(w, "invalid notification", http.StatusBadRequest)
+			return
+		}
+		if err := notifier.Notify(r.Context(), token, notification); err != nil {
+			if errors.Is(err, ErrUnauthorized) {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				http.Error(w, "invalid bearer token", http.StatusUnauthorized)
+				return
+			}
+			http.Error(w, "notification rejected", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
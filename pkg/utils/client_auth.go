@@ -0,0 +1,243 @@
+package utils
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/types"
+)
+
+// clientAssertionType is the client_assertion_type of RFC 7523 assertions.
+const clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// AuthenticateClient adds the client authentication configured in
+// settings.ClientAuth to a request to the authorization server with the given
+// headers and form.
+func AuthenticateClient(ctx context.Context, settings types.GlideSdkSettings, headers map[string]string, form url.Values) error {
+	switch settings.ClientAuth.Method {
+	case "", types.ClientSecretBasic:
+		creds, err := ClientCredentials(ctx, settings)
+		if err != nil {
+			return err
+		}
+		headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(creds.ClientID+":"+creds.ClientSecret))
+	case types.PrivateKeyJWT:
+		clientID, err := ClientID(ctx, settings)
+		if err != nil {
+			return err
+		}
+		assertion, err := clientAssertion(ctx, settings, clientID)
+		if err != nil {
+			return err
+		}
+		form.Set("client_id", clientID)
+		form.Set("client_assertion_type", clientAssertionType)
+		form.Set("client_assertion", assertion)
+	case types.TLSClientAuth:
+		clientID, err := ClientID(ctx, settings)
+		if err != nil {
+			return err
+		}
+		form.Set("client_id", clientID)
+	default:
+		return ConfigError(fmt.Sprintf("unsupported client auth method %q", settings.ClientAuth.Method))
+	}
+	return nil
+}
+
+// clientAssertion returns a JWT identifying clientID to the authorization
+// server, signed with settings.ClientAuth.PrivateKey.
+func clientAssertion(ctx context.Context, settings types.GlideSdkSettings, clientID string) (string, error) {
+	auth := settings.ClientAuth
+	if auth.PrivateKey == nil {
+		return "", ConfigError("clientAuth.privateKey is required for private_key_jwt")
+	}
+	audience := auth.Audience
+	if audience == "" {
+		// OpenID Connect Core section 9: the audience SHOULD be the token
+		// endpoint, which CIBA servers accept for backchannel requests too.
+		audience = EndpointsFor(ctx, settings).TokenEndpoint
+	}
+	lifetime := auth.AssertionLifetime
+	if lifetime <= 0 {
+		lifetime = time.Minute
+	}
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	return SignJWT(auth.PrivateKey, auth.Algorithm, auth.KeyID, map[string]any{
+		"iss": clientID,
+		"sub": clientID,
+		"aud": audience,
+		"jti": hex.EncodeToString(jti),
+		"iat": now.Unix(),
+		"exp": now.Add(lifetime).Unix(),
+	})
+}
+
+// SignJWT signs claims as a compact JWS with key. alg may be RS256, RS384,
+// RS512, PS256, PS384, PS512, ES256, ES384 or ES512; when empty it is
+// derived from the key. key may be backed by an HSM or KMS.
+func SignJWT(key crypto.Signer, alg, kid string, claims any) (string, error) {
+	if alg == "" {
+		alg = defaultAlgorithm(key.Public())
+	}
+	hash, pss, err := jwsHash(alg)
+	if err != nil {
+		return "", err
+	}
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	h := hash.New()
+	h.Write([]byte(signingInput))
+	digest := h.Sum(nil)
+
+	var signature []byte
+	switch pub := key.Public().(type) {
+	case *rsa.PublicKey:
+		if alg[0] != 'R' && alg[0] != 'P' {
+			return "", ConfigError(fmt.Sprintf("algorithm %s does not match an RSA key", alg))
+		}
+		var opts crypto.SignerOpts = hash
+		if pss {
+			opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: hash}
+		}
+		signature, err = key.Sign(rand.Reader, digest, opts)
+	case *ecdsa.PublicKey:
+		if alg[0] != 'E' {
+			return "", ConfigError(fmt.Sprintf("algorithm %s does not match an EC key", alg))
+		}
+		var der []byte
+		der, err = key.Sign(rand.Reader, digest, hash)
+		if err == nil {
+			signature, err = ecdsaJOSESignature(der, (pub.Curve.Params().BitSize+7)/8)
+		}
+	default:
+		return "", ConfigError(fmt.Sprintf("unsupported private key type %T", pub))
+	}
+	if err != nil {
+		return "", fmt.Errorf("[GlideClient] Failed to sign JWT: %w", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func defaultAlgorithm(pub crypto.PublicKey) string {
+	if pub, ok := pub.(*ecdsa.PublicKey); ok {
+		switch pub.Curve.Params().BitSize {
+		case 384:
+			return "ES384"
+		case 521:
+			return "ES512"
+		}
+		return "ES256"
+	}
+	return "RS256"
+}
+
+// jwsHash returns the hash of alg and whether it uses RSA-PSS.
+func jwsHash(alg string) (crypto.Hash, bool, error) {
+	switch alg {
+	case "RS256", "ES256":
+		return crypto.SHA256, false, nil
+	case "RS384", "ES384":
+		return crypto.SHA384, false, nil
+	case "RS512", "ES512":
+		return crypto.SHA512, false, nil
+	case "PS256":
+		return crypto.SHA256, true, nil
+	case "PS384":
+		return crypto.SHA384, true, nil
+	case "PS512":
+		return crypto.SHA512, true, nil
+	}
+	return 0, false, ConfigError(fmt.Sprintf("unsupported signing algorithm %q", alg))
+}
+
+// ecdsaJOSESignature converts an ASN.1 ECDSA signature to the fixed size
+// R || S form used by JWS.
+func ecdsaJOSESignature(der []byte, size int) ([]byte, error) {
+	var sig struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, err
+	}
+	out := make([]byte, 2*size)
+	sig.R.FillBytes(out[:size])
+	sig.S.FillBytes(out[size:])
+	return out, nil
+}
+
+// ParsePrivateKeyPEM parses a PEM encoded PKCS #8, PKCS #1 or SEC 1 RSA or
+// EC private key, e.g. for ClientAuth.PrivateKey.
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ConfigError("no PEM block found in private key")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		switch key := key.(type) {
+		case *rsa.PrivateKey:
+			return key, nil
+		case *ecdsa.PrivateKey:
+			return key, nil
+		}
+		return nil, ConfigError(fmt.Sprintf("unsupported private key type %T", key))
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, ConfigError("private key is not a PKCS #8, PKCS #1 or SEC 1 key")
+}
+
+// NewMTLSClient returns a copy of client presenting cert on every TLS
+// connection. client's Transport must be nil or an *http.Transport.
+func NewMTLSClient(client *http.Client, cert tls.Certificate) (*http.Client, error) {
+	base := HTTPClient(client)
+	var transport *http.Transport
+	switch t := base.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		return nil, ConfigError(fmt.Sprintf("tls_client_auth needs an *http.Transport to add the client certificate to, got %T", t))
+	}
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	mtls := *base
+	mtls.Transport = transport
+	return &mtls, nil
+}
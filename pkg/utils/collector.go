@@ -0,0 +1,311 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/types"
+)
+
+// DefaultLatencyBuckets are the upper bounds in seconds of the request
+// latency histogram, matching Prometheus' default buckets.
+var DefaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// errorTypes names the sentinel errors for use as metric labels.
+var errorTypes = []struct {
+	err  error
+	name string
+}{
+	{ErrConfiguration, "configuration"},
+	{ErrInvalidCredentials, "invalid_credentials"},
+	{ErrInsufficientScope, "insufficient_scope"},
+	{ErrInvalidRequest, "invalid_request"},
+	{ErrUnauthorized, "unauthorized"},
+	{ErrPermissionDenied, "permission_denied"},
+	{ErrConsentRequired, "consent_required"},
+	{ErrNotFound, "not_found"},
+	{ErrRateLimited, "rate_limited"},
+	{ErrUpstream, "upstream"},
+	{ErrInvalidToken, "invalid_token"},
+	{ErrAuthorizationPending, "authorization_pending"},
+	{ErrSlowDown, "slow_down"},
+	{ErrAuthorizationExpired, "authorization_expired"},
+}
+
+// ErrorType returns a low-cardinality label for err: the snake_case name of
+// its sentinel error, "canceled", "timeout", "network" or "unknown". A
+// cancelled or expired context wins over the failure it interrupted.
+func ErrorType(err error) string {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	}
+	var fetchErr *FetchError
+	if errors.As(err, &fetchErr) && !errors.As(err, new(*GlideError)) {
+		operation := ""
+		if req := fetchErr.Response.Request; req != nil && strings.HasSuffix(req.URL.Path, "/oauth2/token") {
+			operation = "token"
+		}
+		code := ""
+		if fetchErr.Body != nil {
+			code = fetchErr.Body.ErrorCode()
+		}
+		err = classify(operation, fetchErr.Response.StatusCode, code)
+	}
+	for _, t := range errorTypes {
+		if errors.Is(err, t.err) {
+			return t.name
+		}
+	}
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &netErr):
+		return "network"
+	}
+	return "unknown"
+}
+
+// observeRequest reports an HTTP attempt to collector, if any.
+func observeRequest(collector types.MetricsCollector, method, rawURL string, resp *http.Response, duration time.Duration, err error) {
+	if collector == nil {
+		return
+	}
+	endpoint := rawURL
+	if u, parseErr := url.Parse(rawURL); parseErr == nil {
+		endpoint = u.Path
+	}
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	collector.ObserveRequest(method, endpoint, status, duration)
+	if err != nil {
+		collector.ObserveError(method, endpoint, ErrorType(err))
+	}
+}
+
+// RequestKey identifies a request counter.
+type RequestKey struct {
+	Method   string
+	Endpoint string
+	Status   int
+}
+
+// EndpointKey identifies a latency histogram.
+type EndpointKey struct {
+	Method   string
+	Endpoint string
+}
+
+// ErrorKey identifies an error counter.
+type ErrorKey struct {
+	Method   string
+	Endpoint string
+	Type     string
+}
+
+// TokenRefreshKey identifies a token refresh counter.
+type TokenRefreshKey struct {
+	Scope   string
+	Success bool
+}
+
+// Histogram is a latency distribution. Counts[i] is the number of
+// observations no greater than Buckets[i], excluding smaller buckets.
+type Histogram struct {
+	Buckets []float64
+	Counts  []uint64
+	Count   uint64
+	Sum     float64
+}
+
+func (h *Histogram) observe(v float64) {
+	for i, le := range h.Buckets {
+		if v <= le {
+			h.Counts[i]++
+			break
+		}
+	}
+	h.Count++
+	h.Sum += v
+}
+
+// CollectorSnapshot is a point-in-time copy of a MemoryCollector.
+type CollectorSnapshot struct {
+	Requests       map[RequestKey]uint64
+	Latency        map[EndpointKey]Histogram
+	Errors         map[ErrorKey]uint64
+	TokenRefreshes map[TokenRefreshKey]uint64
+}
+
+// MemoryCollector aggregates metrics in memory. It serves them in the
+// Prometheus text format through ServeHTTP, so it can be mounted as a
+// /metrics endpoint.
+type MemoryCollector struct {
+	buckets []float64
+
+	mu             sync.Mutex
+	requests       map[RequestKey]uint64
+	latency        map[EndpointKey]*Histogram
+	errors         map[ErrorKey]uint64
+	tokenRefreshes map[TokenRefreshKey]uint64
+}
+
+// NewMemoryCollector returns a collector using buckets for its latency
+// histograms, or DefaultLatencyBuckets when none are given.
+func NewMemoryCollector(buckets ...float64) *MemoryCollector {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &MemoryCollector{
+		buckets:        buckets,
+		requests:       map[RequestKey]uint64{},
+		latency:        map[EndpointKey]*Histogram{},
+		errors:         map[ErrorKey]uint64{},
+		tokenRefreshes: map[TokenRefreshKey]uint64{},
+	}
+}
+
+func (c *MemoryCollector) ObserveRequest(method, endpoint string, status int, duration time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests[RequestKey{method, endpoint, status}]++
+	key := EndpointKey{method, endpoint}
+	h, ok := c.latency[key]
+	if !ok {
+		h = &Histogram{Buckets: c.buckets, Counts: make([]uint64, len(c.buckets))}
+		c.latency[key] = h
+	}
+	h.observe(duration.Seconds())
+}
+
+func (c *MemoryCollector) ObserveError(method, endpoint, errorType string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errors[ErrorKey{method, endpoint, errorType}]++
+}
+
+func (c *MemoryCollector) ObserveTokenRefresh(scope string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokenRefreshes[TokenRefreshKey{scope, err == nil}]++
+}
+
+// Snapshot returns a copy of the collected metrics.
+func (c *MemoryCollector) Snapshot() CollectorSnapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := CollectorSnapshot{
+		Requests:       make(map[RequestKey]uint64, len(c.requests)),
+		Latency:        make(map[EndpointKey]Histogram, len(c.latency)),
+		Errors:         make(map[ErrorKey]uint64, len(c.errors)),
+		TokenRefreshes: make(map[TokenRefreshKey]uint64, len(c.tokenRefreshes)),
+	}
+	for k, v := range c.requests {
+		s.Requests[k] = v
+	}
+	for k, h := range c.latency {
+		copied := *h
+		copied.Counts = append([]uint64(nil), h.Counts...)
+		s.Latency[k] = copied
+	}
+	for k, v := range c.errors {
+		s.Errors[k] = v
+	}
+	for k, v := range c.tokenRefreshes {
+		s.TokenRefreshes[k] = v
+	}
+	return s
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (c *MemoryCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.WritePrometheus(w)
+}
+
+// WritePrometheus writes the metrics to w in the Prometheus text exposition
+// format.
+func (c *MemoryCollector) WritePrometheus(w io.Writer) error {
+	s := c.Snapshot()
+	b := bufio.NewWriter(w)
+
+	writeHeader(b, "glide_http_requests_total", "counter", "HTTP requests sent by the Glide SDK.")
+	requests := sortedKeys(s.Requests, func(k RequestKey) string { return fmt.Sprintf("%s %s %03d", k.Endpoint, k.Method, k.Status) })
+	for _, k := range requests {
+		fmt.Fprintf(b, "glide_http_requests_total{method=%s,endpoint=%s,status=\"%d\"} %d\n", quote(k.Method), quote(k.Endpoint), k.Status, s.Requests[k])
+	}
+
+	writeHeader(b, "glide_http_request_duration_seconds", "histogram", "Latency of HTTP requests sent by the Glide SDK.")
+	endpoints := sortedKeys(s.Latency, func(k EndpointKey) string { return k.Endpoint + " " + k.Method })
+	for _, k := range endpoints {
+		h := s.Latency[k]
+		labels := fmt.Sprintf("method=%s,endpoint=%s", quote(k.Method), quote(k.Endpoint))
+		var cumulative uint64
+		for i, le := range h.Buckets {
+			cumulative += h.Counts[i]
+			fmt.Fprintf(b, "glide_http_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", labels, formatFloat(le), cumulative)
+		}
+		fmt.Fprintf(b, "glide_http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.Count)
+		fmt.Fprintf(b, "glide_http_request_duration_seconds_sum{%s} %s\n", labels, formatFloat(h.Sum))
+		fmt.Fprintf(b, "glide_http_request_duration_seconds_count{%s} %d\n", labels, h.Count)
+	}
+
+	writeHeader(b, "glide_errors_total", "counter", "Failed HTTP requests sent by the Glide SDK, by error type.")
+	errs := sortedKeys(s.Errors, func(k ErrorKey) string { return k.Endpoint + " " + k.Method + " " + k.Type })
+	for _, k := range errs {
+		fmt.Fprintf(b, "glide_errors_total{method=%s,endpoint=%s,type=%s} %d\n", quote(k.Method), quote(k.Endpoint), quote(k.Type), s.Errors[k])
+	}
+
+	writeHeader(b, "glide_token_refreshes_total", "counter", "Client credentials token requests.")
+	refreshes := sortedKeys(s.TokenRefreshes, func(k TokenRefreshKey) string { return fmt.Sprintf("%s %t", k.Scope, k.Success) })
+	for _, k := range refreshes {
+		result := "failure"
+		if k.Success {
+			result = "success"
+		}
+		fmt.Fprintf(b, "glide_token_refreshes_total{scope=%s,result=\"%s\"} %d\n", quote(k.Scope), result, s.TokenRefreshes[k])
+	}
+
+	return b.Flush()
+}
+
+func writeHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func sortedKeys[K comparable, V any](m map[K]V, sortKey func(K) string) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return sortKey(keys[i]) < sortKey(keys[j]) })
+	return keys
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func quote(v string) string {
+	return `"` + labelEscaper.Replace(v) + `"`
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/types"
+)
+
+// MemoryConsentStore keeps pending consents in process memory until they
+// expire. Use a shared store, e.g. FileConsentStore or one backed by Redis,
+// when the user may return to another instance.
+type MemoryConsentStore struct {
+	mu       sync.Mutex
+	consents map[string]types.PendingConsent
+}
+
+// NewMemoryConsentStore creates an empty MemoryConsentStore.
+func NewMemoryConsentStore() *MemoryConsentStore {
+	return &MemoryConsentStore{consents: map[string]types.PendingConsent{}}
+}
+
+func (s *MemoryConsentStore) Put(ctx context.Context, consent *types.PendingConsent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pruneConsents(s.consents, time.Now())
+	s.consents[consent.State] = *consent
+	return nil
+}
+
+func (s *MemoryConsentStore) Take(ctx context.Context, state string) (*types.PendingConsent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	consent, ok := s.consents[state]
+	if !ok {
+		return nil, nil
+	}
+	delete(s.consents, state)
+	if consentExpired(consent, time.Now()) {
+		return nil, nil
+	}
+	return &consent, nil
+}
+
+// FileConsentStore keeps pending consents in a JSON file, so that instances
+// sharing a volume can complete each other's consents. Writes replace the
+// file atomically under the same advisory lock as FileTokenStore, so a
+// consent is taken by one instance only.
+type FileConsentStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileConsentStore creates a FileConsentStore backed by the file at path.
+// The file is created on first write.
+func NewFileConsentStore(path string) *FileConsentStore {
+	return &FileConsentStore{path: path}
+}
+
+func (s *FileConsentStore) Put(ctx context.Context, consent *types.PendingConsent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := lockFile(s.path)
+	if err != nil {
+		return fmt.Errorf("[GlideClient] failed to lock consent store: %w", err)
+	}
+	defer unlock()
+	consents, err := s.load()
+	if err != nil {
+		return err
+	}
+	pruneConsents(consents, time.Now())
+	consents[consent.State] = *consent
+	return s.save(consents)
+}
+
+func (s *FileConsentStore) Take(ctx context.Context, state string) (*types.PendingConsent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := lockFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("[GlideClient] failed to lock consent store: %w", err)
+	}
+	defer unlock()
+	consents, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	consent, ok := consents[state]
+	if !ok {
+		return nil, nil
+	}
+	delete(consents, state)
+	if err := s.save(consents); err != nil {
+		return nil, err
+	}
+	if consentExpired(consent, time.Now()) {
+		return nil, nil
+	}
+	return &consent, nil
+}
+
+func (s *FileConsentStore) load() (map[string]types.PendingConsent, error) {
+	consents := map[string]types.PendingConsent{}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return consents, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("[GlideClient] failed to read consent store: %w", err)
+	}
+	if len(data) == 0 {
+		return consents, nil
+	}
+	if err := json.Unmarshal(data, &consents); err != nil {
+		return nil, fmt.Errorf("[GlideClient] failed to parse consent store: %w", err)
+	}
+	return consents, nil
+}
+
+func (s *FileConsentStore) save(consents map[string]types.PendingConsent) error {
+	data, err := json.Marshal(consents)
+	if err != nil {
+		return fmt.Errorf("[GlideClient] failed to encode consent store: %w", err)
+	}
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return fmt.Errorf("[GlideClient] failed to write consent store: %w", err)
+	}
+	return nil
+}
+
+func consentExpired(consent types.PendingConsent, now time.Time) bool {
+	return !consent.ExpiresAt.IsZero() && !now.Before(consent.ExpiresAt)
+}
+
+func pruneConsents(consents map[string]types.PendingConsent, now time.Time) {
+	for state, consent := range consents {
+		if consentExpired(consent, now) {
+			delete(consents, state)
+		}
+	}
+}
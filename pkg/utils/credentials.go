@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"github.com/joho/godotenv"
+)
+
+// StaticCredentials always returns the same credentials.
+type StaticCredentials types.Credentials
+
+func (c StaticCredentials) Credentials(ctx context.Context) (types.Credentials, error) {
+	return types.Credentials(c), nil
+}
+
+// EnvCredentials reads GLIDE_CLIENT_ID and GLIDE_CLIENT_SECRET on every call,
+// so that updates to the process environment take effect immediately.
+type EnvCredentials struct{}
+
+func (EnvCredentials) Credentials(ctx context.Context) (types.Credentials, error) {
+	return types.Credentials{
+		ClientID:     os.Getenv("GLIDE_CLIENT_ID"),
+		ClientSecret: os.Getenv("GLIDE_CLIENT_SECRET"),
+	}, nil
+}
+
+// FileCredentials reads credentials from a file and reloads it whenever its
+// modification time or size changes, e.g. when a mounted secret is rotated.
+// JSON files use the keys clientId and clientSecret; any other file is read
+// as dotenv with GLIDE_CLIENT_ID and GLIDE_CLIENT_SECRET.
+type FileCredentials struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	creds   types.Credentials
+}
+
+// NewFileCredentials creates a FileCredentials watching the file at path.
+// The file is first read on the first call to Credentials.
+func NewFileCredentials(path string) *FileCredentials {
+	return &FileCredentials{path: path}
+}
+
+func (f *FileCredentials) Credentials(ctx context.Context) (types.Credentials, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return types.Credentials{}, ConfigError(fmt.Sprintf("reading credentials file: %v", err))
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.creds, nil
+	}
+	creds, err := f.load()
+	if err != nil {
+		return types.Credentials{}, err
+	}
+	f.modTime, f.size, f.creds = info.ModTime(), info.Size(), creds
+	return creds, nil
+}
+
+func (f *FileCredentials) load() (types.Credentials, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return types.Credentials{}, ConfigError(fmt.Sprintf("reading credentials file: %v", err))
+	}
+	var creds types.Credentials
+	if strings.EqualFold(filepath.Ext(f.path), ".json") {
+		var file struct {
+			ClientID     string `json:"clientId"`
+			ClientSecret string `json:"clientSecret"`
+		}
+		err = json.Unmarshal(data, &file)
+		creds = types.Credentials{ClientID: file.ClientID, ClientSecret: file.ClientSecret}
+	} else {
+		var env map[string]string
+		env, err = godotenv.UnmarshalBytes(data)
+		creds = types.Credentials{ClientID: env["GLIDE_CLIENT_ID"], ClientSecret: env["GLIDE_CLIENT_SECRET"]}
+	}
+	if err != nil {
+		return types.Credentials{}, ConfigError(fmt.Sprintf("parsing credentials file %s: %v", filepath.Base(f.path), err))
+	}
+	return creds, nil
+}
+
+// ClientCredentials returns the credentials of settings.Credentials, falling
+// back to settings.ClientID for an empty client ID, or settings.ClientID and
+// settings.ClientSecret when no provider is set.
+func ClientCredentials(ctx context.Context, settings types.GlideSdkSettings) (types.Credentials, error) {
+	creds, err := providedCredentials(ctx, settings)
+	if err != nil {
+		return types.Credentials{}, err
+	}
+	if creds.ClientID == "" || creds.ClientSecret == "" {
+		return types.Credentials{}, ConfigError("Client credentials are required to generate a new session")
+	}
+	return creds, nil
+}
+
+// ClientID returns the client ID of settings, resolved like
+// ClientCredentials but without requiring a secret.
+func ClientID(ctx context.Context, settings types.GlideSdkSettings) (string, error) {
+	creds, err := providedCredentials(ctx, settings)
+	if err != nil {
+		return "", err
+	}
+	if creds.ClientID == "" {
+		return "", ConfigError("clientId is required")
+	}
+	return creds.ClientID, nil
+}
+
+// providedCredentials is ClientCredentials without the check that both
+// values are set.
+func providedCredentials(ctx context.Context, settings types.GlideSdkSettings) (types.Credentials, error) {
+	if settings.Credentials == nil {
+		return types.Credentials{ClientID: settings.ClientID, ClientSecret: settings.ClientSecret}, nil
+	}
+	creds, err := settings.Credentials.Credentials(ctx)
+	if err != nil {
+		return types.Credentials{}, err
+	}
+	if creds.ClientID == "" {
+		creds.ClientID = settings.ClientID
+	}
+	return creds, nil
+}
@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/types"
+)
+
+const (
+	// DefaultDiscoveryRefreshInterval is how long an OpenID Provider
+	// configuration is cached.
+	DefaultDiscoveryRefreshInterval = time.Hour
+	// discoveryRetryInterval is how long a failed load is remembered before
+	// the configuration is requested again.
+	discoveryRetryInterval = time.Minute
+)
+
+// DefaultEndpoints returns the endpoints at their default paths under
+// settings.Internal.AuthBaseURL. The JWKS has no default location and is only
+// known through discovery.
+func DefaultEndpoints(settings types.GlideSdkSettings) types.Endpoints {
+	base := strings.TrimSuffix(settings.Internal.AuthBaseURL, "/")
+	return types.Endpoints{
+		Issuer:                            settings.Internal.AuthBaseURL,
+		AuthorizationEndpoint:             base + "/oauth2/auth",
+		TokenEndpoint:                     base + "/oauth2/token",
+		BackchannelAuthenticationEndpoint: base + "/oauth2/backchannel-authentication",
+	}
+}
+
+// EndpointsFor returns the endpoints resolved by settings.Endpoints, or the
+// default endpoints when it is nil.
+func EndpointsFor(ctx context.Context, settings types.GlideSdkSettings) types.Endpoints {
+	if settings.Endpoints != nil {
+		return settings.Endpoints.Endpoints(ctx)
+	}
+	return DefaultEndpoints(settings)
+}
+
+// Discovery loads and caches the OpenID Provider configuration published at
+// Internal.AuthBaseURL + "/.well-known/openid-configuration", reloading it
+// every DiscoveryRefreshInterval. Concurrent loads share a single request.
+// It is safe for concurrent use.
+type Discovery struct {
+	settings types.GlideSdkSettings
+	logger   *slog.Logger
+	refresh  time.Duration
+
+	mu        sync.Mutex
+	doc       *types.Endpoints
+	fetchedAt time.Time
+	err       error
+	failedAt  time.Time
+	inflight  *discoveryCall
+}
+
+type discoveryCall struct {
+	done chan struct{}
+	doc  *types.Endpoints
+	err  error
+}
+
+// NewDiscovery creates a Discovery for the authorization server of settings.
+func NewDiscovery(settings types.GlideSdkSettings) *Discovery {
+	refresh := settings.DiscoveryRefreshInterval
+	if refresh <= 0 {
+		refresh = DefaultDiscoveryRefreshInterval
+	}
+	return &Discovery{settings: settings, logger: NewLogger(settings), refresh: refresh}
+}
+
+// Endpoints returns the default endpoints, overridden by the advertised ones
+// when settings.UseDiscovery is set and the configuration can be loaded.
+func (d *Discovery) Endpoints(ctx context.Context) types.Endpoints {
+	endpoints := DefaultEndpoints(d.settings)
+	if !d.settings.UseDiscovery {
+		return endpoints
+	}
+	doc, err := d.Document(ctx)
+	if err != nil {
+		return endpoints
+	}
+	for _, field := range []struct{ advertised, endpoint *string }{
+		{&doc.Issuer, &endpoints.Issuer},
+		{&doc.AuthorizationEndpoint, &endpoints.AuthorizationEndpoint},
+		{&doc.TokenEndpoint, &endpoints.TokenEndpoint},
+		{&doc.BackchannelAuthenticationEndpoint, &endpoints.BackchannelAuthenticationEndpoint},
+		{&doc.JWKSURI, &endpoints.JWKSURI},
+	} {
+		if *field.advertised != "" {
+			*field.endpoint = *field.advertised
+		}
+	}
+	return endpoints
+}
+
+// Document returns the cached configuration, loading it when missing or
+// older than the refresh interval. A stale configuration is returned if
+// reloading fails or while it is being reloaded; failures are retried after a
+// minute at the earliest.
+func (d *Discovery) Document(ctx context.Context) (*types.Endpoints, error) {
+	d.mu.Lock()
+	if d.doc != nil && time.Since(d.fetchedAt) < d.refresh {
+		defer d.mu.Unlock()
+		return d.doc, nil
+	}
+	if d.err != nil && time.Since(d.failedAt) < discoveryRetryInterval {
+		defer d.mu.Unlock()
+		if d.doc != nil {
+			return d.doc, nil
+		}
+		return nil, d.err
+	}
+	call := d.inflight
+	if call == nil {
+		call = d.startLoad(ctx)
+	}
+	stale := d.doc
+	d.mu.Unlock()
+	if stale != nil {
+		return stale, nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-call.done:
+		return call.doc, call.err
+	}
+}
+
+// startLoad fetches the configuration in the background; d.mu must be held.
+// The fetch is detached from the caller's cancellation since other callers
+// may be waiting on it.
+func (d *Discovery) startLoad(ctx context.Context) *discoveryCall {
+	call := &discoveryCall{done: make(chan struct{})}
+	d.inflight = call
+	go func() {
+		doc, err := d.fetch(context.WithoutCancel(ctx))
+		d.mu.Lock()
+		if err != nil {
+			d.err, d.failedAt = err, time.Now()
+			if d.doc != nil {
+				d.logger.Warn("Failed to refresh OpenID configuration, using cached copy", "error", err)
+			} else {
+				d.logger.Warn("Failed to load OpenID configuration, using default endpoints", "error", err)
+			}
+		} else {
+			d.doc, d.fetchedAt, d.err = doc, time.Now(), nil
+		}
+		d.inflight = nil
+		d.mu.Unlock()
+		call.doc, call.err = doc, err
+		close(call.done)
+	}()
+	return call
+}
+
+func (d *Discovery) fetch(ctx context.Context) (*types.Endpoints, error) {
+	resp, err := FetchXWithContext(ctx, strings.TrimSuffix(d.settings.Internal.AuthBaseURL, "/")+"/.well-known/openid-configuration", FetchXInput{
+		Client:     d.settings.HTTPClient,
+		Logger:     d.logger,
+		Retry:      d.settings.Retry,
+		Collector:  d.settings.Collector,
+		Idempotent: true,
+		Method:     "GET",
+	})
+	if err != nil {
+		return nil, NewAPIError("oidc", "discovery", err, nil)
+	}
+	var doc types.Endpoints
+	if err := resp.JSON(&doc); err != nil {
+		return nil, NewAPIError("oidc", "discovery", err, nil)
+	}
+	// OpenID Connect Discovery 1.0 section 4.3: the issuer must be the URL
+	// the configuration was retrieved from, or its endpoints could redirect
+	// tokens to another server.
+	if want := strings.TrimSuffix(d.settings.Internal.AuthBaseURL, "/"); strings.TrimSuffix(doc.Issuer, "/") != want {
+		return nil, &GlideError{Kind: ErrUpstream, API: "oidc", Operation: "discovery", Message: fmt.Sprintf("issuer %q does not match %q", doc.Issuer, want)}
+	}
+	return &doc, nil
+}
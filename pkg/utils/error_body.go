@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// Common CAMARA error codes.
+const (
+	CodeInvalidArgument    = "INVALID_ARGUMENT"
+	CodeUnauthenticated    = "UNAUTHENTICATED"
+	CodePermissionDenied   = "PERMISSION_DENIED"
+	CodeNotFound           = "NOT_FOUND"
+	CodeIdentifierNotFound = "IDENTIFIER_NOT_FOUND"
+	CodeTooManyRequests    = "TOO_MANY_REQUESTS"
+	CodeQuotaExceeded      = "QUOTA_EXCEEDED"
+	CodeServiceUnavailable = "SERVICE_UNAVAILABLE"
+)
+
+// ErrorBody is a decoded error response. CAMARA envelopes fill Status, Code
+// and Message; OAuth errors fill OAuthError and ErrorDescription; RFC 7807
+// problem details fill Type, Title, Detail and Instance.
+type ErrorBody struct {
+	Status           int
+	Code             string
+	Message          string
+	OAuthError       string
+	ErrorDescription string
+	Type             string
+	Title            string
+	Detail           string
+	Instance         string
+	// Fields holds the whole decoded object, including non-standard members.
+	Fields map[string]interface{}
+}
+
+// ParseErrorBody decodes data as an error response. It returns nil if data
+// is not a JSON object.
+func ParseErrorBody(data []byte) *ErrorBody {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+		return nil
+	}
+	return &ErrorBody{
+		Status:           fieldInt(fields, "status"),
+		Code:             fieldString(fields, "code"),
+		Message:          fieldString(fields, "message"),
+		OAuthError:       fieldString(fields, "error"),
+		ErrorDescription: fieldString(fields, "error_description"),
+		Type:             fieldString(fields, "type"),
+		Title:            fieldString(fields, "title"),
+		Detail:           fieldString(fields, "detail"),
+		Instance:         fieldString(fields, "instance"),
+		Fields:           fields,
+	}
+}
+
+// ErrorCode returns the CAMARA code, falling back to the OAuth error.
+func (b *ErrorBody) ErrorCode() string {
+	if b.Code != "" {
+		return b.Code
+	}
+	return b.OAuthError
+}
+
+// Description returns the most specific human readable message available.
+func (b *ErrorBody) Description() string {
+	switch {
+	case b.Message != "":
+		return b.Message
+	case b.ErrorDescription != "":
+		return b.ErrorDescription
+	case b.Detail != "":
+		return b.Detail
+	}
+	return b.Title
+}
+
+// Summary returns "CODE: description", or whichever part is present.
+func (b *ErrorBody) Summary() string {
+	code, desc := b.ErrorCode(), b.Description()
+	switch {
+	case code != "" && desc != "":
+		return code + ": " + desc
+	case code != "":
+		return code
+	}
+	return desc
+}
+
+func fieldString(fields map[string]interface{}, key string) string {
+	if v, ok := fields[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+func fieldInt(fields map[string]interface{}, key string) int {
+	switch v := fields[key].(type) {
+	case float64:
+		return int(v)
+	case string:
+		n, _ := strconv.Atoi(v)
+		return n
+	}
+	return 0
+}
@@ -0,0 +1,240 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/GlideApis/sdk-go/pkg/types"
+)
+
+// Sentinel errors classifying failures. Match them with errors.Is; use
+// errors.As with *GlideError for status code, operator and request details.
+var (
+	ErrConfiguration      = errors.New("invalid configuration")
+	ErrInvalidCredentials = errors.New("invalid client credentials")
+	ErrInsufficientScope  = errors.New("client does not have required scopes")
+	ErrInvalidRequest     = errors.New("invalid request")
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrPermissionDenied   = errors.New("permission denied")
+	ErrConsentRequired    = errors.New("consent required")
+	ErrNotFound           = errors.New("not found")
+	ErrRateLimited        = errors.New("rate limited")
+	ErrUpstream           = errors.New("upstream error")
+	ErrInvalidToken       = errors.New("invalid token")
+	// ErrAuthorizationPending and ErrSlowDown mean a backchannel
+	// authentication request still awaits the user. Polling continues after
+	// the interval, increased on ErrSlowDown.
+	ErrAuthorizationPending = errors.New("authorization pending")
+	ErrSlowDown             = errors.New("polling too fast")
+	// ErrAuthorizationExpired means a backchannel authentication request
+	// expired before the user approved it.
+	ErrAuthorizationExpired = errors.New("authorization request expired")
+)
+
+// GlideError describes a failed SDK operation.
+type GlideError struct {
+	// Kind is one of the sentinel errors above, or nil if unclassified.
+	Kind error
+	// API and Operation name the failed call, e.g. "sim-swap" and "check".
+	API       string
+	Operation string
+	// StatusCode is the HTTP status returned by the server, if any.
+	StatusCode int
+	// Operator is the operator serving the request when known.
+	Operator string
+	// RequestID is the server's request or correlation ID when present.
+	RequestID string
+	// Code is the CAMARA error code (e.g. PERMISSION_DENIED) or OAuth error
+	// (e.g. invalid_scope) returned by the server.
+	Code string
+	// Description is the server's human readable error message.
+	Description string
+	// Body is the raw error body and ErrorBody its decoding, if any.
+	Body      string
+	ErrorBody *ErrorBody
+	Message   string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *GlideError) Error() string {
+	var b strings.Builder
+	b.WriteString("[GlideClient]")
+	if e.API != "" {
+		b.WriteString(" [" + e.API + "]")
+	}
+	if e.Operation != "" {
+		b.WriteString(" " + e.Operation)
+	}
+	if e.API != "" || e.Operation != "" {
+		b.WriteString(":")
+	}
+	b.WriteString(" ")
+	switch {
+	case e.Message != "":
+		b.WriteString(e.Message)
+	case e.Kind != nil:
+		b.WriteString(e.Kind.Error())
+	case e.Err != nil:
+		b.WriteString(e.Err.Error())
+	}
+	if e.ErrorBody != nil {
+		if summary := e.ErrorBody.Summary(); summary != "" {
+			b.WriteString(": " + summary)
+		}
+	}
+	if e.StatusCode != 0 {
+		fmt.Fprintf(&b, " (status %d", e.StatusCode)
+		if e.RequestID != "" {
+			fmt.Fprintf(&b, ", request id %s", e.RequestID)
+		}
+		b.WriteString(")")
+	}
+	return b.String()
+}
+
+// Unwrap exposes both the classification and the underlying error to
+// errors.Is and errors.As.
+func (e *GlideError) Unwrap() []error {
+	var errs []error
+	if e.Kind != nil {
+		errs = append(errs, e.Kind)
+	}
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	return errs
+}
+
+// ConfigError returns a GlideError for missing or invalid settings.
+func ConfigError(message string) error {
+	return &GlideError{Kind: ErrConfiguration, Message: message}
+}
+
+// InvalidRequestError returns a GlideError for invalid caller input.
+func InvalidRequestError(message string) error {
+	return &GlideError{Kind: ErrInvalidRequest, Message: message}
+}
+
+// InvalidTokenError returns a GlideError for an ID token or authorization
+// response that failed validation.
+func InvalidTokenError(message string) error {
+	return &GlideError{Kind: ErrInvalidToken, Message: message}
+}
+
+// AuthorizationResponseError returns a GlideError for an OAuth error sent
+// to the redirect URI, e.g. error=access_denied.
+func AuthorizationResponseError(api, code, description string) error {
+	kind := classify("", 0, code)
+	if kind == nil {
+		kind = ErrInvalidRequest
+	}
+	message := "authorization server returned " + code
+	if description != "" {
+		message += ": " + description
+	}
+	return &GlideError{Kind: kind, API: api, Operation: "callback", Code: code, Description: description, Message: message}
+}
+
+// AuthorizationPendingError returns a GlideError for a backchannel
+// authentication request of api the user has not answered yet.
+func AuthorizationPendingError(api string) error {
+	return &GlideError{Kind: ErrAuthorizationPending, API: api, Operation: "token", Code: "authorization_pending", Message: "authorization request is still pending"}
+}
+
+// AuthorizationExpiredError returns a GlideError for a backchannel
+// authentication request of api that expired while waiting for the user.
+func AuthorizationExpiredError(api string) error {
+	return &GlideError{Kind: ErrAuthorizationExpired, API: api, Operation: "token", Code: "expired_token", Message: "authorization request expired before the user approved it"}
+}
+
+// NewAPIError wraps err, as returned by FetchX for api and operation, in a
+// GlideError classified by status code and error body. session, if not nil,
+// is used to resolve the operator. Errors without an HTTP response, such as
+// connection failures, keep their original error as Err.
+func NewAPIError(api, operation string, err error, session *types.Session) error {
+	if err == nil {
+		return nil
+	}
+	var existing *GlideError
+	if errors.As(err, &existing) {
+		return err
+	}
+	gErr := &GlideError{API: api, Operation: operation, Err: err}
+	if session != nil {
+		if operator, opErr := GetOperator(session); opErr == nil {
+			gErr.Operator = operator
+		}
+	}
+	var fetchErr *FetchError
+	if !errors.As(err, &fetchErr) {
+		return gErr
+	}
+	gErr.StatusCode = fetchErr.Response.StatusCode
+	gErr.RequestID = requestID(fetchErr.Response.Header)
+	gErr.Body = fetchErr.Data
+	gErr.ErrorBody = fetchErr.Body
+	if gErr.ErrorBody == nil {
+		gErr.ErrorBody = ParseErrorBody([]byte(fetchErr.Data))
+	}
+	if gErr.ErrorBody != nil {
+		gErr.Code = gErr.ErrorBody.ErrorCode()
+		gErr.Description = gErr.ErrorBody.Description()
+	}
+	gErr.Kind = classify(operation, gErr.StatusCode, gErr.Code)
+	return gErr
+}
+
+func requestID(header http.Header) string {
+	for _, name := range []string{"X-Request-Id", "X-Correlator", "X-Correlation-Id"} {
+		if id := header.Get(name); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// classify maps a status code and server error code to a sentinel error.
+func classify(operation string, status int, code string) error {
+	switch strings.ToLower(code) {
+	case "invalid_client", "unauthorized_client":
+		return ErrInvalidCredentials
+	case "invalid_scope", "insufficient_scope":
+		return ErrInsufficientScope
+	case "consent_required", "consent_pending":
+		return ErrConsentRequired
+	case "permission_denied", "access_denied":
+		return ErrPermissionDenied
+	case "not_found", "identifier_not_found", "device_not_found":
+		return ErrNotFound
+	case "too_many_requests", "quota_exceeded":
+		return ErrRateLimited
+	case "unauthenticated":
+		return ErrUnauthorized
+	case "authorization_pending":
+		return ErrAuthorizationPending
+	case "slow_down":
+		return ErrSlowDown
+	case "expired_token":
+		return ErrAuthorizationExpired
+	}
+	switch {
+	case status == http.StatusUnauthorized && operation == "token":
+		return ErrInvalidCredentials
+	case status == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case status == http.StatusForbidden:
+		return ErrPermissionDenied
+	case status == http.StatusNotFound:
+		return ErrNotFound
+	case status == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case status >= 500:
+		return ErrUpstream
+	case status >= 400:
+		return ErrInvalidRequest
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package utils
+
+// lockFile is a no-op where flock is unavailable, leaving only the
+// in-process lock of the store.
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,446 @@
+package utils
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/types"
+)
+
+const (
+	// DefaultJWKSTTL is how long the issuer's signing keys are cached.
+	DefaultJWKSTTL = time.Hour
+	// jwksMissInterval limits reloads of the JWKS triggered by unknown key
+	// IDs.
+	jwksMissInterval = 30 * time.Second
+	// DefaultClockSkew is the leeway applied to exp and iat.
+	DefaultClockSkew = time.Minute
+)
+
+// KeySet loads and caches the JSON Web Key Set advertised as jwks_uri in the
+// OpenID Provider configuration. Unknown key IDs trigger a reload, so keys
+// rotated by the issuer are picked up. Concurrent loads share a single
+// request. It is safe for concurrent use.
+type KeySet struct {
+	settings  types.GlideSdkSettings
+	logger    *slog.Logger
+	discovery *Discovery
+
+	mu        sync.Mutex
+	keys      map[string]signingKey
+	fetchedAt time.Time
+	missedAt  time.Time
+	inflight  *keySetCall
+}
+
+// signingKey is a published key and the algorithm its JWK is restricted
+// to, if any.
+type signingKey struct {
+	key crypto.PublicKey
+	alg string
+}
+
+type keySetCall struct {
+	done chan struct{}
+	err  error
+}
+
+// NewKeySet creates a KeySet locating the JWKS through discovery.
+func NewKeySet(settings types.GlideSdkSettings, discovery *Discovery) *KeySet {
+	return &KeySet{settings: settings, logger: NewLogger(settings), discovery: discovery}
+}
+
+// Keys returns the keys usable to verify a signature made with alg: the key
+// with ID kid, or every key when kid is empty. Keys of another type or
+// curve, or published for another algorithm, are left out.
+func (k *KeySet) Keys(ctx context.Context, kid, alg string) ([]crypto.PublicKey, error) {
+	k.mu.Lock()
+	miss := kid != "" && k.keys != nil && k.keys[kid].key == nil && time.Since(k.missedAt) >= jwksMissInterval
+	if miss {
+		k.missedAt = time.Now()
+	}
+	if k.keys == nil || time.Since(k.fetchedAt) >= DefaultJWKSTTL || miss {
+		call := k.inflight
+		if call == nil {
+			call = k.startLoad(ctx)
+		}
+		k.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-call.done:
+		}
+		k.mu.Lock()
+		if k.keys == nil {
+			k.mu.Unlock()
+			return nil, call.err
+		}
+	}
+	defer k.mu.Unlock()
+
+	var candidates []signingKey
+	if kid != "" {
+		key, ok := k.keys[kid]
+		if !ok {
+			return nil, InvalidTokenError(fmt.Sprintf("unknown signing key %q", kid))
+		}
+		candidates = append(candidates, key)
+	} else {
+		for _, key := range k.keys {
+			candidates = append(candidates, key)
+		}
+	}
+	keys := make([]crypto.PublicKey, 0, len(candidates))
+	for _, key := range candidates {
+		if (key.alg == "" || key.alg == alg) && keyMatchesAlg(key.key, alg) {
+			keys = append(keys, key.key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, InvalidTokenError(fmt.Sprintf("no signing key for %s", alg))
+	}
+	return keys, nil
+}
+
+// startLoad fetches the JWKS in the background; k.mu must be held. The
+// fetch is detached from the caller's cancellation since other callers may
+// be waiting on it.
+func (k *KeySet) startLoad(ctx context.Context) *keySetCall {
+	call := &keySetCall{done: make(chan struct{})}
+	k.inflight = call
+	go func() {
+		keys, err := k.fetch(context.WithoutCancel(ctx))
+		k.mu.Lock()
+		if err != nil {
+			if k.keys != nil {
+				k.logger.Warn("Failed to refresh JWKS, using cached keys", "error", err)
+			}
+		} else {
+			k.keys, k.fetchedAt = keys, time.Now()
+		}
+		k.inflight = nil
+		k.mu.Unlock()
+		call.err = err
+		close(call.done)
+	}()
+	return call
+}
+
+func (k *KeySet) fetch(ctx context.Context) (map[string]signingKey, error) {
+	doc, err := k.discovery.Document(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if doc.JWKSURI == "" {
+		return nil, ConfigError("OpenID configuration has no jwks_uri")
+	}
+	resp, err := FetchXWithContext(ctx, doc.JWKSURI, FetchXInput{
+		Client:     k.settings.HTTPClient,
+		Logger:     k.logger,
+		Retry:      k.settings.Retry,
+		Collector:  k.settings.Collector,
+		Idempotent: true,
+		Method:     "GET",
+	})
+	if err != nil {
+		return nil, NewAPIError("oidc", "jwks", err, nil)
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := resp.JSON(&set); err != nil {
+		return nil, NewAPIError("oidc", "jwks", err, nil)
+	}
+	keys := map[string]signingKey{}
+	for i, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			k.logger.Warn("Skipping unsupported JWK", "kid", jwk.Kid, "error", err)
+			continue
+		}
+		kid := jwk.Kid
+		if kid == "" {
+			kid = fmt.Sprintf("#%d", i)
+		}
+		keys[kid] = signingKey{key: key, alg: jwk.Alg}
+	}
+	return keys, nil
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (j jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	decode := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+	switch j.Kty {
+	case "RSA":
+		n, err := decode(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(j.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", j.Crv)
+		}
+		x, err := decode(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", j.Kty)
+}
+
+// JWKSVerifier verifies ID tokens signed with the issuer's published keys.
+// It checks the signature, iss, aud, azp, exp and, when given, nonce.
+type JWKSVerifier struct {
+	settings  types.GlideSdkSettings
+	discovery *Discovery
+	keys      *KeySet
+}
+
+// NewIDTokenVerifier creates a JWKSVerifier for the authorization server and
+// client of settings. It shares the discovery cache of settings.Endpoints
+// when that is a *Discovery.
+func NewIDTokenVerifier(settings types.GlideSdkSettings) *JWKSVerifier {
+	discovery, ok := settings.Endpoints.(*Discovery)
+	if !ok {
+		discovery = NewDiscovery(settings)
+	}
+	return &JWKSVerifier{settings: settings, discovery: discovery, keys: NewKeySet(settings, discovery)}
+}
+
+func (v *JWKSVerifier) Verify(ctx context.Context, idToken, nonce string) (*types.IDTokenClaims, error) {
+	parts := strings.Split(idToken, ".")
+	if len(parts) != 3 {
+		return nil, InvalidTokenError("malformed JWT")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err == nil {
+		err = json.Unmarshal(headerJSON, &header)
+	}
+	if err != nil {
+		return nil, InvalidTokenError(fmt.Sprintf("decoding JWT header: %v", err))
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, InvalidTokenError(fmt.Sprintf("decoding JWT signature: %v", err))
+	}
+	keys, err := v.keys.Keys(ctx, header.Kid, header.Alg)
+	if err != nil {
+		return nil, err
+	}
+	verified := false
+	for _, key := range keys {
+		if verifyJWS(header.Alg, key, parts[0]+"."+parts[1], signature) == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, InvalidTokenError(fmt.Sprintf("invalid %s signature", header.Alg))
+	}
+
+	var raw map[string]any
+	if err := DecodeJWTClaims(idToken, &raw); err != nil {
+		return nil, err
+	}
+	var claims struct {
+		Iss         string   `json:"iss"`
+		Sub         string   `json:"sub"`
+		Aud         audience `json:"aud"`
+		Azp         string   `json:"azp"`
+		Exp         int64    `json:"exp"`
+		Iat         int64    `json:"iat"`
+		Nonce       string   `json:"nonce"`
+		PhoneNumber string   `json:"phone_number"`
+		Ext         struct {
+			Operator string `json:"operator"`
+		} `json:"ext"`
+	}
+	if err := DecodeJWTClaims(idToken, &claims); err != nil {
+		return nil, err
+	}
+
+	issuer := v.settings.Internal.AuthBaseURL
+	if doc, err := v.discovery.Document(ctx); err == nil && doc.Issuer != "" {
+		issuer = doc.Issuer
+	}
+	clientID, err := ClientID(ctx, v.settings)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	switch {
+	case claims.Iss != issuer:
+		return nil, InvalidTokenError(fmt.Sprintf("issuer %q does not match %q", claims.Iss, issuer))
+	case !claims.Aud.contains(clientID):
+		return nil, InvalidTokenError("audience does not include the client ID")
+	case claims.Azp != "" && claims.Azp != clientID:
+		return nil, InvalidTokenError("authorized party is not the client ID")
+	case claims.Exp == 0 || now.After(time.Unix(claims.Exp, 0).Add(DefaultClockSkew)):
+		return nil, InvalidTokenError("token is expired")
+	case claims.Iat != 0 && now.Add(DefaultClockSkew).Before(time.Unix(claims.Iat, 0)):
+		return nil, InvalidTokenError("token is issued in the future")
+	case nonce != "" && !SecureCompare(claims.Nonce, nonce):
+		return nil, InvalidTokenError("nonce does not match the authorization request")
+	}
+
+	result := &types.IDTokenClaims{
+		Issuer:      claims.Iss,
+		Subject:     claims.Sub,
+		Audience:    claims.Aud,
+		ExpiresAt:   time.Unix(claims.Exp, 0),
+		Nonce:       claims.Nonce,
+		PhoneNumber: claims.PhoneNumber,
+		Operator:    claims.Ext.Operator,
+		Raw:         raw,
+	}
+	if claims.Iat != 0 {
+		result.IssuedAt = time.Unix(claims.Iat, 0)
+	}
+	return result, nil
+}
+
+// DecodeJWTClaims decodes the payload of a compact JWT into v without
+// verifying its signature.
+func DecodeJWTClaims(token string, v any) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return InvalidTokenError("malformed JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return InvalidTokenError(fmt.Sprintf("decoding JWT payload: %v", err))
+	}
+	if err := json.Unmarshal(payload, v); err != nil {
+		return InvalidTokenError(fmt.Sprintf("decoding JWT claims: %v", err))
+	}
+	return nil
+}
+
+// audience decodes an aud claim given as a string or an array.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+func (a audience) contains(value string) bool {
+	for _, v := range a {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// keyMatchesAlg reports whether key has the type, and for ECDSA the curve,
+// that alg requires, so that a header cannot select an algorithm the key
+// was not made for.
+func keyMatchesAlg(key crypto.PublicKey, alg string) bool {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		switch alg {
+		case "RS256", "RS384", "RS512", "PS256", "PS384", "PS512":
+			return true
+		}
+	case *ecdsa.PublicKey:
+		switch alg {
+		case "ES256":
+			return key.Curve == elliptic.P256()
+		case "ES384":
+			return key.Curve == elliptic.P384()
+		case "ES512":
+			return key.Curve == elliptic.P521()
+		}
+	}
+	return false
+}
+
+// verifyJWS checks signature over signingInput with key for alg. Only
+// asymmetric algorithms are accepted, with keys of the matching type and
+// curve.
+func verifyJWS(alg string, key crypto.PublicKey, signingInput string, signature []byte) error {
+	if !keyMatchesAlg(key, alg) {
+		return InvalidTokenError(fmt.Sprintf("key does not match algorithm %q", alg))
+	}
+	hash, pss, err := jwsHash(alg)
+	if err != nil {
+		return err
+	}
+	h := hash.New()
+	h.Write([]byte(signingInput))
+	digest := h.Sum(nil)
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if pss {
+			return rsa.VerifyPSS(key, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthAuto, Hash: hash})
+		}
+		return rsa.VerifyPKCS1v15(key, hash, digest, signature)
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) == 2*size {
+			r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+			if ecdsa.Verify(key, digest, r, s) {
+				return nil
+			}
+		}
+	}
+	return InvalidTokenError("signature verification failed")
+}
@@ -1,7 +1,13 @@
 package utils
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/types"
 )
 
 type LogLevel int
@@ -14,41 +20,86 @@ const (
 	ERROR                 //4 - Shows only ERROR
 )
 
-var (
-	currentLogLevel = ERROR // default log level
-)
+// SlogLevel converts the level to its log/slog equivalent. UNSET maps to
+// ERROR, the SDK default.
+func (l LogLevel) SlogLevel() slog.Level {
+	switch l {
+	case DEBUG:
+		return slog.LevelDebug
+	case INFO:
+		return slog.LevelInfo
+	case WARN:
+		return slog.LevelWarn
+	}
+	return slog.LevelError
+}
 
-var logger = struct {
-	Debug func(format string, v ...interface{})
-	Info  func(format string, v ...interface{})
-	Warn  func(format string, v ...interface{})
-	Error func(format string, v ...interface{})
-}{
-	Debug: func(format string, v ...interface{}) {
-		if currentLogLevel == DEBUG { // Show debug only at DEBUG level (1)
-			log.Printf("DEBUG: "+format, v...)
-		}
-	},
-	Info: func(format string, v ...interface{}) {
-		if currentLogLevel <= INFO { // Show info at DEBUG or INFO level (1,2)
-			log.Printf("INFO: "+format, v...)
-		}
-	},
-	Warn: func(format string, v ...interface{}) {
-		if currentLogLevel <= WARN { // Show warn if we're at DEBUG, INFO, or WARN level
-			log.Printf("WARN: "+format, v...)
-		}
-	},
-	Error: func(format string, v ...interface{}) {
-		if currentLogLevel <= ERROR { // Show error for all levels
-			log.Printf("ERROR: "+format, v...)
-		}
-	},
+var currentLogLevel = new(slog.LevelVar)
+
+func init() {
+	currentLogLevel.Set(slog.LevelError) // default log level
 }
 
-// Export the logger
-var Logger = logger
+// Logger is the package default logger, used by code that is not given a
+// client's logger. It writes text records to stderr at the level set by
+// SetLogLevel, with tokens and PII redacted. GlideClients log through their
+// own logger built by NewLogger instead.
+var Logger = slog.New(NewRedactingHandler(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: currentLogLevel})))
 
+// SetLogLevel sets the level of the package default logger.
 func SetLogLevel(level LogLevel) {
-	currentLogLevel = level
+	currentLogLevel.Set(level.SlogLevel())
+}
+
+// NewLogger builds the logger for a client from settings: settings.Logger
+// when set, or a stderr logger at settings.Internal.LogLevel otherwise,
+// redacted unless settings.DisableLogRedaction is true.
+func NewLogger(settings types.GlideSdkSettings) *slog.Logger {
+	var handler slog.Handler
+	if settings.Logger != nil {
+		handler = settings.Logger.Handler()
+	} else {
+		level := LogLevel(settings.Internal.LogLevel).SlogLevel()
+		handler = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})
+	}
+	if !settings.DisableLogRedaction {
+		handler = NewRedactingHandler(handler)
+	}
+	return slog.New(handler)
+}
+
+// loggerOr returns logger, or the package default logger if it is nil.
+func loggerOr(logger *slog.Logger) *slog.Logger {
+	if logger != nil {
+		return logger
+	}
+	return Logger
+}
+
+// LogOperation records the outcome of an SDK operation with its api,
+// operation, duration and, for failures, status and operator.
+func LogOperation(ctx context.Context, logger *slog.Logger, api, operation string, start time.Time, err error, attrs ...any) {
+	attrs = append([]any{
+		slog.String("api", api),
+		slog.String("operation", operation),
+		slog.Duration("duration", time.Since(start)),
+	}, attrs...)
+	if err == nil {
+		logger.DebugContext(ctx, "operation completed", attrs...)
+		return
+	}
+	var gErr *GlideError
+	if errors.As(err, &gErr) {
+		if gErr.StatusCode != 0 {
+			attrs = append(attrs, slog.Int("status", gErr.StatusCode))
+		}
+		if gErr.Operator != "" {
+			attrs = append(attrs, slog.String("operator", gErr.Operator))
+		}
+		if gErr.RequestID != "" {
+			attrs = append(attrs, slog.String("requestId", gErr.RequestID))
+		}
+	}
+	attrs = append(attrs, slog.Any("error", err))
+	logger.ErrorContext(ctx, "operation failed", attrs...)
 }
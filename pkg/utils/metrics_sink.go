@@ -0,0 +1,258 @@
+package utils
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/types"
+)
+
+// NoopMetricsSink discards all metrics.
+type NoopMetricsSink struct{}
+
+func (NoopMetricsSink) Report(context.Context, types.MetricInfo) {}
+func (NoopMetricsSink) Flush(context.Context) error              { return nil }
+func (NoopMetricsSink) Close(context.Context) error              { return nil }
+
+// MemoryMetricsSink records metrics in memory, e.g. for tests.
+type MemoryMetricsSink struct {
+	mu      sync.Mutex
+	metrics []types.MetricInfo
+}
+
+func NewMemoryMetricsSink() *MemoryMetricsSink {
+	return &MemoryMetricsSink{}
+}
+
+func (s *MemoryMetricsSink) Report(_ context.Context, metric types.MetricInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metrics = append(s.metrics, metric)
+}
+
+func (s *MemoryMetricsSink) Flush(context.Context) error { return nil }
+func (s *MemoryMetricsSink) Close(context.Context) error { return nil }
+
+// Metrics returns a copy of the recorded metrics in reporting order.
+func (s *MemoryMetricsSink) Metrics() []types.MetricInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]types.MetricInfo(nil), s.metrics...)
+}
+
+// Reset discards the recorded metrics.
+func (s *MemoryMetricsSink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metrics = nil
+}
+
+// HTTPMetricsSinkOptions configures an HTTPMetricsSink. Zero values select
+// the defaults.
+type HTTPMetricsSinkOptions struct {
+	// Client sends the metrics; DefaultHTTPClient is used when nil.
+	Client *http.Client
+	// Logger receives delivery failures; the package Logger is used when nil.
+	Logger *slog.Logger
+	// QueueSize bounds the number of buffered metrics (default 1000). Metrics
+	// reported while the queue is full are dropped.
+	QueueSize int
+	// BatchSize is the number of metrics that triggers a send (default 50).
+	BatchSize int
+	// FlushInterval is the longest a metric waits in the queue (default 1s).
+	FlushInterval time.Duration
+	// Retry controls retries of failed sends (default 3 attempts, 2s/4s).
+	Retry *types.RetryPolicy
+	// Concurrency is the number of metrics sent at once (default 8).
+	Concurrency int
+	// SendTimeout bounds the delivery of one metric, retries included
+	// (default 10s), so that a hanging collector cannot stall the worker.
+	SendTimeout time.Duration
+}
+
+type queuedMetric struct {
+	ctx    context.Context
+	metric types.MetricInfo
+}
+
+// HTTPMetricsSink posts metrics to a collector URL from a background worker.
+// Report only enqueues, so metrics never add latency to API calls.
+type HTTPMetricsSink struct {
+	url     string
+	opts    HTTPMetricsSinkOptions
+	logger  *slog.Logger
+	queue   chan queuedMetric
+	flushes chan chan struct{}
+	closing chan struct{}
+	done    chan struct{}
+	// abort cancels sends in flight once Close gives up.
+	abort   context.Context
+	cancel  context.CancelFunc
+	once    sync.Once
+	closed  atomic.Bool
+	dropped atomic.Int64
+}
+
+// NewHTTPMetricsSink starts a sink posting to url. Call Close to deliver
+// buffered metrics and stop the worker.
+func NewHTTPMetricsSink(url string, opts HTTPMetricsSinkOptions) *HTTPMetricsSink {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1000
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 50
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.Retry == nil {
+		opts.Retry = metricRetryPolicy
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 8
+	}
+	if opts.SendTimeout <= 0 {
+		opts.SendTimeout = 10 * time.Second
+	}
+	abort, cancel := context.WithCancel(context.Background())
+	s := &HTTPMetricsSink{
+		url:     url,
+		opts:    opts,
+		logger:  loggerOr(opts.Logger),
+		queue:   make(chan queuedMetric, opts.QueueSize),
+		flushes: make(chan chan struct{}),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+		abort:   abort,
+		cancel:  cancel,
+	}
+	go s.run()
+	return s
+}
+
+// Report enqueues metric, dropping it if the queue is full or the sink is
+// closed. The values of ctx, but not its cancellation, are kept for sending.
+func (s *HTTPMetricsSink) Report(ctx context.Context, metric types.MetricInfo) {
+	if s.closed.Load() {
+		s.drop(ctx, metric, "sink closed")
+		return
+	}
+	select {
+	case s.queue <- queuedMetric{ctx: context.WithoutCancel(ctx), metric: metric}:
+	default:
+		s.drop(ctx, metric, "queue full")
+	}
+}
+
+// Dropped returns the number of metrics discarded because the queue was full
+// or the sink was closed.
+func (s *HTTPMetricsSink) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Flush sends all metrics reported before the call.
+func (s *HTTPMetricsSink) Flush(ctx context.Context) error {
+	ack := make(chan struct{})
+	select {
+	case s.flushes <- ack:
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting metrics and sends the buffered ones. If ctx expires
+// first, sends in flight are aborted and the remaining metrics are not
+// delivered.
+func (s *HTTPMetricsSink) Close(ctx context.Context) error {
+	s.once.Do(func() {
+		s.closed.Store(true)
+		close(s.closing)
+	})
+	select {
+	case <-s.done:
+		s.cancel()
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		return ctx.Err()
+	}
+}
+
+func (s *HTTPMetricsSink) drop(ctx context.Context, metric types.MetricInfo, reason string) {
+	s.dropped.Add(1)
+	s.logger.WarnContext(ctx, "Dropping metric", "metric", metric.MetricName, "reason", reason)
+}
+
+func (s *HTTPMetricsSink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.opts.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]queuedMetric, 0, s.opts.BatchSize)
+	for {
+		select {
+		case m := <-s.queue:
+			batch = append(batch, m)
+			if len(batch) >= s.opts.BatchSize {
+				batch = s.send(batch)
+			}
+		case <-ticker.C:
+			batch = s.send(batch)
+		case ack := <-s.flushes:
+			batch = s.send(s.drain(batch))
+			close(ack)
+		case <-s.closing:
+			s.send(s.drain(batch))
+			return
+		}
+	}
+}
+
+// drain moves everything currently queued into batch.
+func (s *HTTPMetricsSink) drain(batch []queuedMetric) []queuedMetric {
+	for {
+		select {
+		case m := <-s.queue:
+			batch = append(batch, m)
+		default:
+			return batch
+		}
+	}
+}
+
+// send posts the metrics of batch, up to Concurrency at once, and returns
+// batch emptied for reuse.
+func (s *HTTPMetricsSink) send(batch []queuedMetric) []queuedMetric {
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, s.opts.Concurrency)
+	for _, m := range batch {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(m queuedMetric) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			ctx, cancel := context.WithTimeout(m.ctx, s.opts.SendTimeout)
+			defer cancel()
+			stop := context.AfterFunc(s.abort, cancel)
+			defer stop()
+			if err := sendMetric(ctx, s.opts.Client, s.logger, s.url, s.opts.Retry, metricPayload(m.metric)); err != nil {
+				s.logger.ErrorContext(ctx, "Failed to report metric", "metric", m.metric.MetricName, "error", err)
+			}
+		}(m)
+	}
+	wg.Wait()
+	return batch[:0]
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"context"
+	"sync"
+
+	"github.com/GlideApis/sdk-go/pkg/types"
+)
+
+// Observers fans events out to a changing set of observers.
+type Observers struct {
+	mu        sync.RWMutex
+	nextID    int
+	observers map[int]types.Observer
+}
+
+func NewObservers() *Observers {
+	return &Observers{observers: map[int]types.Observer{}}
+}
+
+// Add registers observer and returns a function that removes it.
+func (o *Observers) Add(observer types.Observer) (remove func()) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	id := o.nextID
+	o.nextID++
+	o.observers[id] = observer
+	return func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		delete(o.observers, id)
+	}
+}
+
+// OnEvent delivers event to every registered observer.
+func (o *Observers) OnEvent(ctx context.Context, event types.Event) {
+	o.mu.RLock()
+	observers := make([]types.Observer, 0, len(o.observers))
+	for _, observer := range o.observers {
+		observers = append(observers, observer)
+	}
+	o.mu.RUnlock()
+	for _, observer := range observers {
+		observer.OnEvent(ctx, event)
+	}
+}
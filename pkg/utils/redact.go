@@ -0,0 +1,245 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	jwtPattern        = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	authHeaderPattern = regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9._~+/=-]+`)
+	emailPattern      = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	phonePattern      = regexp.MustCompile(`(\+|%2[Bb])(\d{6,15})`)
+	// barePhonePattern matches numbers written without '+' where the context
+	// says they are phone numbers, e.g. msisdn=34630844689 or tel:34630844689.
+	// Other digit runs, such as timestamps and request IDs, are kept.
+	barePhonePattern = regexp.MustCompile(`(?i)((?:msisdn|phone|phone_?number)=|(?:tel|msisdn)(?::|%3A))(\d{6,15})\b`)
+)
+
+// sensitiveKeys maps normalised attribute keys (lower case, without '_' and
+// '-') to how their values are redacted.
+var sensitiveKeys = map[string]func(string) string{
+	"token":                func(string) string { return redacted },
+	"accesstoken":          func(string) string { return redacted },
+	"refreshtoken":         func(string) string { return redacted },
+	"idtoken":              func(string) string { return redacted },
+	"authorization":        func(string) string { return redacted },
+	"clientsecret":         func(string) string { return redacted },
+	"secret":               func(string) string { return redacted },
+	"password":             func(string) string { return redacted },
+	"code":                 func(string) string { return redacted },
+	"phonenumber":          MaskPhoneNumber,
+	"phone":                MaskPhoneNumber,
+	"msisdn":               MaskPhoneNumber,
+	"loginhint":            RedactString,
+	"email":                MaskEmail,
+	"iddocument":           func(string) string { return redacted },
+	"name":                 func(string) string { return redacted },
+	"givenname":            func(string) string { return redacted },
+	"familyname":           func(string) string { return redacted },
+	"middlenames":          func(string) string { return redacted },
+	"familynameatbirth":    func(string) string { return redacted },
+	"namekanahankaku":      func(string) string { return redacted },
+	"namekanazenkaku":      func(string) string { return redacted },
+	"address":              func(string) string { return redacted },
+	"streetname":           func(string) string { return redacted },
+	"streetnumber":         func(string) string { return redacted },
+	"postalcode":           func(string) string { return redacted },
+	"housenumberextension": func(string) string { return redacted },
+	"birthdate":            func(string) string { return redacted },
+	"gender":               func(string) string { return redacted },
+}
+
+// NewRedactingHandler wraps next so that tokens, phone numbers, emails and
+// KYC fields are masked in messages and attributes before being handled.
+func NewRedactingHandler(next slog.Handler) slog.Handler {
+	return &redactingHandler{next: next}
+}
+
+type redactingHandler struct {
+	next slog.Handler
+}
+
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, r slog.Record) error {
+	record := slog.NewRecord(r.Time, r.Level, RedactString(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		record.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, record)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redactedAttrs := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redactedAttrs[i] = redactAttr(a)
+	}
+	return &redactingHandler{next: h.next.WithAttrs(redactedAttrs)}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{next: h.next.WithGroup(name)}
+}
+
+func redactAttr(a slog.Attr) slog.Attr {
+	value := a.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		group := value.Group()
+		redactedGroup := make([]any, len(group))
+		for i, ga := range group {
+			redactedGroup[i] = redactAttr(ga)
+		}
+		return slog.Group(a.Key, redactedGroup...)
+	}
+	if mask, ok := sensitiveKeys[normaliseKey(a.Key)]; ok {
+		return slog.String(a.Key, mask(valueString(value)))
+	}
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, RedactString(value.String()))
+	case slog.KindAny:
+		switch v := value.Any().(type) {
+		case error:
+			return slog.String(a.Key, RedactString(v.Error()))
+		case []byte:
+			return slog.String(a.Key, RedactString(string(v)))
+		}
+		if isComposite(value.Any()) {
+			return slog.Any(a.Key, redactComposite(value.Any()))
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: value}
+}
+
+// isComposite reports whether v is a struct, map or slice, possibly behind
+// pointers, whose fields may hold sensitive values.
+func isComposite(v any) bool {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		return true
+	}
+	return false
+}
+
+// redactComposite returns the JSON form of v, e.g. a UserIdentifier or KYC
+// request, with sensitive fields masked as for attributes of the same name
+// and free text redacted.
+func redactComposite(v any) any {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return RedactString(fmt.Sprintf("%+v", v))
+	}
+	var decoded any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return RedactString(string(data))
+	}
+	return redactJSON(decoded)
+}
+
+func redactJSON(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if mask, ok := sensitiveKeys[normaliseKey(key)]; ok && field != nil {
+				if s, isString := field.(string); isString {
+					v[key] = mask(s)
+				} else {
+					v[key] = redacted
+				}
+				continue
+			}
+			v[key] = redactJSON(field)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redactJSON(item)
+		}
+	case string:
+		return RedactString(v)
+	}
+	return v
+}
+
+func valueString(value slog.Value) string {
+	if value.Kind() == slog.KindAny {
+		if b, ok := value.Any().([]byte); ok {
+			return string(b)
+		}
+	}
+	return value.String()
+}
+
+func normaliseKey(key string) string {
+	return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(key))
+}
+
+// RedactString masks tokens, authorization headers, emails and E.164 phone
+// numbers found in free text, including URL encoded ones (%2B...) and ones
+// without the leading '+' after msisdn=, phone= or tel:.
+func RedactString(s string) string {
+	s = jwtPattern.ReplaceAllString(s, redacted)
+	s = authHeaderPattern.ReplaceAllString(s, "$1 "+redacted)
+	s = emailPattern.ReplaceAllStringFunc(s, MaskEmail)
+	s = maskNumbers(phonePattern, s)
+	s = maskNumbers(barePhonePattern, s)
+	return s
+}
+
+// maskNumbers masks the phone numbers matched by the second group of
+// pattern in s, keeping the prefix matched by the first.
+func maskNumbers(pattern *regexp.Regexp, s string) string {
+	return pattern.ReplaceAllStringFunc(s, func(match string) string {
+		digits := pattern.FindStringSubmatchIndex(match)[4]
+		return match[:digits] + MaskPhoneNumber(match[digits:])
+	})
+}
+
+// MaskPhoneNumber keeps only the last two digits of a phone number.
+func MaskPhoneNumber(phone string) string {
+	digits := 0
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	var b strings.Builder
+	seen := 0
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			seen++
+			if seen <= digits-2 {
+				b.WriteByte('*')
+				continue
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// MaskEmail keeps the first character of the local part and the domain.
+func MaskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return redacted
+	}
+	return email[:1] + "***" + email[at:]
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/types"
+)
+
+var defaultRetryableStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// DefaultRetryPolicy returns the policy used by GlideClient when none is set.
+func DefaultRetryPolicy() *types.RetryPolicy {
+	return &types.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// isIdempotentMethod reports whether method can be safely repeated.
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// shouldRetry decides whether another attempt may be made after a failure.
+// resp is nil when err is a transport error.
+func shouldRetry(policy *types.RetryPolicy, idempotent bool, resp *http.Response, err error) bool {
+	if resp == nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+		// the request may have reached the server, so only repeat safe calls
+		return idempotent
+	}
+	codes := policy.RetryableStatusCodes
+	if codes == nil {
+		codes = defaultRetryableStatusCodes
+	}
+	for _, code := range codes {
+		if resp.StatusCode == code {
+			// a rate limited request was not processed, so it is safe to repeat
+			return idempotent || code == http.StatusTooManyRequests
+		}
+	}
+	return false
+}
+
+// retryDelay returns how long to wait before attempt+1, honouring the
+// Retry-After header of resp when present. It returns false when the server
+// asks to wait longer than MaxBackoff or than is left before ctx's deadline,
+// since retrying any earlier would only be rejected again.
+func retryDelay(ctx context.Context, policy *types.RetryPolicy, attempt int, resp *http.Response) (time.Duration, bool) {
+	if resp != nil {
+		if after, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			if policy.MaxBackoff > 0 && after > policy.MaxBackoff {
+				return 0, false
+			}
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < after {
+				return 0, false
+			}
+			return after, true
+		}
+	}
+	multiplier := policy.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	delay := float64(policy.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if policy.Jitter > 0 {
+		delay += delay * policy.Jitter * (2*rand.Float64() - 1)
+	}
+	if policy.MaxBackoff > 0 && delay > float64(policy.MaxBackoff) {
+		delay = float64(policy.MaxBackoff)
+	}
+	return time.Duration(delay), true
+}
+
+// parseRetryAfter parses a Retry-After header in either seconds or HTTP date form.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := time.Until(date); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
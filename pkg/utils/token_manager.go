@@ -0,0 +1,301 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultRefreshBefore is how long before ExpiresAt a cached token is
+// refreshed in the background, or half its lifetime if that is shorter.
+const DefaultRefreshBefore = time.Minute
+
+// TokenManager caches client credentials tokens per scope set. Concurrent
+// requests for the same scopes share a single call to /oauth2/token, and
+// tokens close to expiry are refreshed in the background while the cached
+// token keeps being served. When settings carry a TokenStore, tokens are
+// looked up there before minting and written back after. It is safe for
+// concurrent use.
+type TokenManager struct {
+	settings      types.GlideSdkSettings
+	logger        *slog.Logger
+	tracer        trace.Tracer
+	refreshBefore time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*tokenEntry
+	// generation is bumped by every invalidation, so that mints started
+	// before it are not cached or stored.
+	generation uint64
+}
+
+type tokenEntry struct {
+	session *types.Session
+	// obtainedAt is when session was minted or read from the store.
+	obtainedAt time.Time
+	inflight   *tokenCall
+}
+
+type tokenCall struct {
+	done    chan struct{}
+	session *types.Session
+	err     error
+}
+
+// NewTokenManager creates a TokenManager minting tokens with the client
+// credentials, endpoints and HTTP client in settings.
+func NewTokenManager(settings types.GlideSdkSettings) *TokenManager {
+	return &TokenManager{
+		settings:      settings,
+		logger:        NewLogger(settings),
+		tracer:        NewTracer(settings),
+		refreshBefore: DefaultRefreshBefore,
+		entries:       map[string]*tokenEntry{},
+	}
+}
+
+// Token returns a valid access token carrying scopes, minting one if needed.
+func (m *TokenManager) Token(ctx context.Context, scopes ...string) (*types.Session, error) {
+	key := ScopeKey(scopes)
+	now := time.Now()
+
+	m.mu.Lock()
+	entry, ok := m.entries[key]
+	if !ok {
+		entry = &tokenEntry{}
+		m.entries[key] = entry
+	}
+	if s := entry.session; s != nil && s.ExpiresAt > now.Unix() {
+		if time.Unix(s.ExpiresAt, 0).Sub(now) <= m.refreshWindow(entry) && entry.inflight == nil {
+			m.logger.Debug("Refreshing token ahead of expiry", "scopes", key)
+			m.startRefresh(ctx, key, entry)
+		}
+		m.mu.Unlock()
+		return s, nil
+	}
+	call := entry.inflight
+	if call == nil {
+		call = m.startRefresh(ctx, key, entry)
+	}
+	m.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-call.done:
+		return call.session, call.err
+	}
+}
+
+// Invalidate drops the cached token for scopes, locally and in the token
+// store, so the next call mints a new one.
+func (m *TokenManager) Invalidate(ctx context.Context, scopes ...string) {
+	key := ScopeKey(scopes)
+	m.mu.Lock()
+	m.generation++
+	if entry, ok := m.entries[key]; ok {
+		entry.session = nil
+	}
+	m.mu.Unlock()
+	m.deleteStored(ctx, key)
+}
+
+// InvalidateAll drops every token this manager has cached or stored, e.g.
+// after the client's credentials were rotated.
+func (m *TokenManager) InvalidateAll(ctx context.Context) {
+	m.mu.Lock()
+	m.generation++
+	keys := make([]string, 0, len(m.entries))
+	for key, entry := range m.entries {
+		entry.session = nil
+		keys = append(keys, key)
+	}
+	m.mu.Unlock()
+	for _, key := range keys {
+		m.deleteStored(ctx, key)
+	}
+}
+
+// deleteStored removes the stored token for the scope key, if any.
+func (m *TokenManager) deleteStored(ctx context.Context, key string) {
+	if m.settings.TokenStore == nil {
+		return
+	}
+	storeKey, err := m.storeKey(ctx, key)
+	if err == nil {
+		err = m.settings.TokenStore.Delete(ctx, storeKey)
+	}
+	if err != nil {
+		m.logger.Warn("Failed to delete token from store", "scopes", key, "error", err)
+	}
+}
+
+// refreshWindow returns how long before expiry the token of entry is
+// refreshed: refreshBefore, but at most half the token's lifetime, so that
+// short-lived tokens are not refreshed on every call.
+func (m *TokenManager) refreshWindow(entry *tokenEntry) time.Duration {
+	lifetime := time.Unix(entry.session.ExpiresAt, 0).Sub(entry.obtainedAt)
+	return min(m.refreshBefore, lifetime/2)
+}
+
+// startRefresh mints a token in the background; m.mu must be held. The mint
+// is detached from the caller's cancellation since other callers may be
+// waiting on it. Its token is not cached if an invalidation happened
+// meanwhile.
+func (m *TokenManager) startRefresh(ctx context.Context, key string, entry *tokenEntry) *tokenCall {
+	call := &tokenCall{done: make(chan struct{})}
+	entry.inflight = call
+	generation := m.generation
+	go func() {
+		session, err := m.fetch(context.WithoutCancel(ctx), key, generation)
+		m.mu.Lock()
+		if err == nil && m.generation == generation {
+			entry.session, entry.obtainedAt = session, time.Now()
+		}
+		if err != nil && entry.session != nil && entry.session.ExpiresAt > time.Now().Unix() {
+			// Callers keep getting the cached token, so nobody else sees
+			// the error.
+			m.logger.Warn("Failed to refresh token ahead of expiry", "scopes", key, "error", err)
+		}
+		entry.inflight = nil
+		m.mu.Unlock()
+		call.session, call.err = session, err
+		close(call.done)
+	}()
+	return call
+}
+
+// fetch returns a token from the token store if it holds a fresh one, and
+// mints and stores a new token otherwise. Store failures are logged rather
+// than returned so that an unavailable store degrades to minting. The token
+// is only stored while the manager is still at generation.
+func (m *TokenManager) fetch(ctx context.Context, key string, generation uint64) (*types.Session, error) {
+	store := m.settings.TokenStore
+	var storeKey types.TokenKey
+	if store != nil {
+		var err error
+		if storeKey, err = m.storeKey(ctx, key); err != nil {
+			return nil, err
+		}
+		session, err := store.Get(ctx, storeKey)
+		if err != nil {
+			m.logger.Warn("Failed to read token from store", "scopes", key, "error", err)
+		} else if session != nil && session.ExpiresAt > time.Now().Add(m.refreshBefore).Unix() {
+			m.logger.Debug("Using token from store", "scopes", key)
+			return session, nil
+		}
+	}
+	session, err := m.mint(ctx, strings.Fields(key))
+	if err != nil {
+		return nil, err
+	}
+	if store != nil && m.current(generation) {
+		if err := store.Put(ctx, storeKey, session); err != nil {
+			m.logger.Warn("Failed to write token to store", "scopes", key, "error", err)
+		}
+		// An invalidation racing with Put may have deleted the entry
+		// before it was written.
+		if !m.current(generation) {
+			m.deleteStored(ctx, key)
+		}
+	}
+	return session, nil
+}
+
+// current reports whether no invalidation happened since generation.
+func (m *TokenManager) current(generation uint64) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.generation == generation
+}
+
+// storeKey returns the token store key of a scope key. It uses the client ID
+// of the credentials provider, so that clients configured only through
+// providers do not share entries.
+func (m *TokenManager) storeKey(ctx context.Context, scopeKey string) (types.TokenKey, error) {
+	clientID, err := ClientID(ctx, m.settings)
+	if err != nil {
+		return types.TokenKey{}, err
+	}
+	return types.TokenKey{ClientID: clientID, Scope: scopeKey}, nil
+}
+
+func (m *TokenManager) mint(ctx context.Context, scopes []string) (_ *types.Session, err error) {
+	ctx, span := StartSpan(ctx, m.tracer, "oauth2", "token", attribute.String("glide.scope", strings.Join(scopes, " ")))
+	defer func() {
+		EndSpan(span, err)
+		if m.settings.Collector != nil {
+			m.settings.Collector.ObserveTokenRefresh(strings.Join(scopes, " "), err)
+		}
+	}()
+	headers := map[string]string{"Content-Type": "application/x-www-form-urlencoded"}
+	form := url.Values{
+		"grant_type": {"client_credentials"},
+		"scope":      {strings.Join(scopes, " ")},
+	}
+	if err := AuthenticateClient(ctx, m.settings, headers, form); err != nil {
+		m.logger.Error("Failed to authenticate token request", "error", err)
+		return nil, err
+	}
+
+	resp, err := FetchXWithContext(ctx, EndpointsFor(ctx, m.settings).TokenEndpoint, FetchXInput{
+		Client:     m.settings.HTTPClient,
+		Logger:     m.logger,
+		Retry:      m.settings.Retry,
+		Collector:  m.settings.Collector,
+		Idempotent: true,
+		Method:     "POST",
+		Headers:    headers,
+		Body:       form.Encode(),
+	})
+	if err != nil {
+		err = NewAPIError(strings.Join(scopes, " "), "token", err, nil)
+		if errors.Is(err, ErrInvalidCredentials) {
+			// The secret was likely rotated: tokens minted with the old one
+			// may be revoked too, so mint afresh with the next credentials.
+			m.InvalidateAll(ctx)
+		}
+		return nil, err
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+		Scope       string `json:"scope"`
+	}
+	if err := resp.JSON(&body); err != nil {
+		return nil, fmt.Errorf("[GlideClient] Failed to parse response: %w", err)
+	}
+
+	return &types.Session{
+		AccessToken: body.AccessToken,
+		ExpiresAt:   time.Now().Unix() + body.ExpiresIn,
+		Scopes:      strings.Split(body.Scope, " "),
+	}, nil
+}
+
+// ScopeKey normalises a scope set into a stable cache key.
+func ScopeKey(scopes []string) string {
+	sorted := make([]string, 0, len(scopes))
+	seen := map[string]bool{}
+	for _, scope := range scopes {
+		for _, s := range strings.Fields(scope) {
+			if !seen[s] {
+				seen[s] = true
+				sorted = append(sorted, s)
+			}
+		}
+	}
+	sort.Strings(sorted)
+	return strings.Join(sorted, " ")
+}
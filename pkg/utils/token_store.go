@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/GlideApis/sdk-go/pkg/types"
+)
+
+// MemoryTokenStore keeps tokens in process memory.
+type MemoryTokenStore struct {
+	mu     sync.RWMutex
+	tokens map[types.TokenKey]types.Session
+}
+
+// NewMemoryTokenStore creates an empty MemoryTokenStore.
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{tokens: map[types.TokenKey]types.Session{}}
+}
+
+func (s *MemoryTokenStore) Get(ctx context.Context, key types.TokenKey) (*types.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	session, ok := s.tokens[key]
+	if !ok {
+		return nil, nil
+	}
+	return &session, nil
+}
+
+func (s *MemoryTokenStore) Put(ctx context.Context, key types.TokenKey, session *types.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[key] = *session
+	return nil
+}
+
+func (s *MemoryTokenStore) Delete(ctx context.Context, key types.TokenKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, key)
+	return nil
+}
+
+// FileTokenStore keeps tokens in a JSON file, so that processes sharing a
+// volume can reuse each other's tokens. Writes replace the file atomically
+// and, on Unix, hold an advisory lock on a sidecar ".lock" file so that
+// concurrent writers do not lose each other's entries. Elsewhere only
+// writers in the same process are serialised.
+type FileTokenStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileTokenStore creates a FileTokenStore backed by the file at path. The
+// file is created on first write.
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{path: path}
+}
+
+func (s *FileTokenStore) Get(ctx context.Context, key types.TokenKey) (*types.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tokens, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	session, ok := tokens[key.String()]
+	if !ok {
+		return nil, nil
+	}
+	return &session, nil
+}
+
+func (s *FileTokenStore) Put(ctx context.Context, key types.TokenKey, session *types.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := lockFile(s.path)
+	if err != nil {
+		return fmt.Errorf("[GlideClient] failed to lock token store: %w", err)
+	}
+	defer unlock()
+	tokens, err := s.load()
+	if err != nil {
+		return err
+	}
+	tokens[key.String()] = *session
+	return s.save(tokens)
+}
+
+func (s *FileTokenStore) Delete(ctx context.Context, key types.TokenKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := lockFile(s.path)
+	if err != nil {
+		return fmt.Errorf("[GlideClient] failed to lock token store: %w", err)
+	}
+	defer unlock()
+	tokens, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := tokens[key.String()]; !ok {
+		return nil
+	}
+	delete(tokens, key.String())
+	return s.save(tokens)
+}
+
+func (s *FileTokenStore) load() (map[string]types.Session, error) {
+	tokens := map[string]types.Session{}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("[GlideClient] failed to read token store: %w", err)
+	}
+	if len(data) == 0 {
+		return tokens, nil
+	}
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("[GlideClient] failed to parse token store: %w", err)
+	}
+	return tokens, nil
+}
+
+func (s *FileTokenStore) save(tokens map[string]types.Session) error {
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		return fmt.Errorf("[GlideClient] failed to encode token store: %w", err)
+	}
+	if err := writeFileAtomic(s.path, data); err != nil {
+		return fmt.Errorf("[GlideClient] failed to write token store: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic replaces the file at path with data, readable only by the
+// owner.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/GlideApis/sdk-go/pkg/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the instrumentation scope of the SDK's spans.
+const TracerName = "github.com/GlideApis/sdk-go"
+
+// traceContext writes W3C traceparent and tracestate headers.
+var traceContext = propagation.TraceContext{}
+
+// NewTracer returns the tracer for a client from settings.TracerProvider,
+// falling back to the global OpenTelemetry provider.
+func NewTracer(settings types.GlideSdkSettings) trace.Tracer {
+	provider := settings.TracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(TracerName)
+}
+
+// StartSpan starts the span of an SDK operation, named "<api>.<operation>".
+func StartSpan(ctx context.Context, tracer trace.Tracer, api, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append([]attribute.KeyValue{
+		attribute.String("glide.api", api),
+		attribute.String("glide.operation", operation),
+	}, attrs...)
+	return tracer.Start(ctx, api+"."+operation, trace.WithAttributes(attrs...))
+}
+
+// EndSpan ends span, recording err with its status, operator and request id.
+// Error messages are redacted since they may echo request data.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		var gErr *GlideError
+		if errors.As(err, &gErr) {
+			if gErr.StatusCode != 0 {
+				span.SetAttributes(attribute.Int("http.response.status_code", gErr.StatusCode))
+			}
+			if gErr.Operator != "" {
+				span.SetAttributes(attribute.String("glide.operator", gErr.Operator))
+			}
+			if gErr.RequestID != "" {
+				span.SetAttributes(attribute.String("glide.request_id", gErr.RequestID))
+			}
+		}
+		msg := RedactString(err.Error())
+		span.AddEvent("exception", trace.WithAttributes(
+			attribute.String("exception.type", fmt.Sprintf("%T", err)),
+			attribute.String("exception.message", msg),
+		))
+		span.SetStatus(codes.Error, msg)
+	}
+	span.End()
+}
+
+// SetSpanOperator records operator on the current span.
+func SetSpanOperator(ctx context.Context, operator string) {
+	if operator != "" {
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("glide.operator", operator))
+	}
+}
+
+// SetSpanSessionOperator records the operator of session on the current span.
+func SetSpanSessionOperator(ctx context.Context, session *types.Session) {
+	if !trace.SpanFromContext(ctx).IsRecording() {
+		return
+	}
+	if operator, err := GetOperator(session); err == nil {
+		SetSpanOperator(ctx, operator)
+	}
+}
+
+// startHTTPSpan starts a client span for an outbound request as a child of the
+// span in ctx, using that span's provider.
+func startHTTPSpan(ctx context.Context, method, rawURL string) (context.Context, trace.Span) {
+	tracer := trace.SpanFromContext(ctx).TracerProvider().Tracer(TracerName)
+	attrs := []attribute.KeyValue{attribute.String("http.request.method", method)}
+	if u, err := url.Parse(rawURL); err == nil {
+		// Drop the query, which may carry identifiers.
+		u.RawQuery = ""
+		u.User = nil
+		attrs = append(attrs,
+			attribute.String("url.full", u.String()),
+			attribute.String("server.address", u.Hostname()),
+		)
+	}
+	return tracer.Start(ctx, method, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// endHTTPSpan records the outcome of a request on its span and ends it.
+func endHTTPSpan(span trace.Span, resp *http.Response, err error) {
+	if resp != nil {
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+		if resp.StatusCode >= 400 {
+			span.SetStatus(codes.Error, resp.Status)
+		}
+	} else if err != nil {
+		span.SetStatus(codes.Error, RedactString(err.Error()))
+	}
+	span.End()
+}
@@ -1,12 +1,13 @@
 package utils
 
 import (
-	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"log/slog"
 	"net/http"
 	"os"
 	"regexp"
@@ -14,6 +15,7 @@ import (
 	"time"
 
 	"github.com/GlideApis/sdk-go/pkg/types"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 // HTTPResponseError represents an HTTP error response
@@ -49,10 +51,19 @@ func FormatPhoneNumber(phoneNumber string) string {
 type FetchError struct {
 	Response *http.Response
 	Data     string
+	// Body is Data decoded as a CAMARA, OAuth or RFC 7807 error, or nil if
+	// Data is not a JSON object.
+	Body *ErrorBody
 }
 
 func (e *FetchError) Error() string {
-	return fmt.Sprintf("Fetch Error: %d %s", e.Response.StatusCode, e.Response.Status)
+	msg := fmt.Sprintf("Fetch Error: %d %s", e.Response.StatusCode, e.Response.Status)
+	if e.Body != nil {
+		if summary := e.Body.Summary(); summary != "" {
+			msg += ": " + summary
+		}
+	}
+	return msg
 }
 
 // FetchXInput represents input for FetchX function
@@ -60,6 +71,29 @@ type FetchXInput struct {
 	Method  string
 	Headers map[string]string
 	Body    string
+	// Client sends the request; DefaultHTTPClient is used when nil.
+	Client *http.Client
+	// Logger receives request logs; the package Logger is used when nil.
+	Logger *slog.Logger
+	// Retry enables retries of transient failures; nil means a single attempt.
+	Retry *types.RetryPolicy
+	// Idempotent marks a non-GET call as safe to repeat after a 5xx or a
+	// connection error.
+	Idempotent bool
+	// Collector receives request and error metrics for each attempt.
+	Collector types.MetricsCollector
+}
+
+// DefaultHTTPClient is used for requests that are not given a client, so
+// connections are pooled across calls.
+var DefaultHTTPClient = &http.Client{}
+
+// HTTPClient returns client, or DefaultHTTPClient if client is nil.
+func HTTPClient(client *http.Client) *http.Client {
+	if client != nil {
+		return client
+	}
+	return DefaultHTTPClient
 }
 
 // FetchXResponse represents the response from FetchX function
@@ -82,33 +116,76 @@ func (r *FetchXResponse) OK() bool {
 
 // FetchX performs an HTTP request
 func FetchX(url string, input FetchXInput) (*FetchXResponse, error) {
-	client := &http.Client{}
+	return FetchXWithContext(context.Background(), url, input)
+}
+
+// FetchXWithContext performs an HTTP request bound to ctx, so deadlines and
+// cancellation of the caller abort the request. Transient failures are
+// retried according to input.Retry.
+func FetchXWithContext(ctx context.Context, url string, input FetchXInput) (*FetchXResponse, error) {
+	maxAttempts := 1
+	if input.Retry != nil && input.Retry.MaxAttempts > 1 {
+		maxAttempts = input.Retry.MaxAttempts
+	}
+	idempotent := input.Idempotent || isIdempotentMethod(input.Method)
+	for attempt := 1; ; attempt++ {
+		res, resp, err := fetchOnce(ctx, url, input)
+		if err == nil || attempt >= maxAttempts || !shouldRetry(input.Retry, idempotent, resp, err) {
+			return res, err
+		}
+		delay, ok := retryDelay(ctx, input.Retry, attempt, resp)
+		if !ok {
+			return res, err
+		}
+		loggerOr(input.Logger).DebugContext(ctx, "Retrying request", "method", input.Method, "url", url, "delay", delay, "attempt", attempt, "error", err)
+		if sleepErr := sleepContext(ctx, delay); sleepErr != nil {
+			// Keep the last attempt's error, e.g. its *FetchError, in the
+			// chain alongside ctx's error.
+			return nil, fmt.Errorf("%w (last attempt: %w)", sleepErr, err)
+		}
+	}
+}
 
-	req, err := http.NewRequest(input.Method, url, strings.NewReader(input.Body))
+// fetchOnce performs a single attempt. The raw response is returned alongside
+// any error so the caller can inspect status and Retry-After.
+func fetchOnce(ctx context.Context, url string, input FetchXInput) (res *FetchXResponse, resp *http.Response, err error) {
+	client := HTTPClient(input.Client)
+
+	ctx, span := startHTTPSpan(ctx, input.Method, url)
+	start := time.Now()
+	defer func() {
+		endHTTPSpan(span, resp, err)
+		observeRequest(input.Collector, input.Method, url, resp, time.Since(start), err)
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, input.Method, url, strings.NewReader(input.Body))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	for k, v := range input.Headers {
 		req.Header.Set(k, v)
 	}
+	traceContext.Inject(ctx, propagation.HeaderCarrier(req.Header))
 
-	resp, err := client.Do(req)
+	resp, err = client.Do(req)
 	if err != nil {
-		return nil, err
+		loggerOr(input.Logger).DebugContext(ctx, "HTTP request failed", "method", input.Method, "url", url, "duration", time.Since(start), "error", err)
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
+	loggerOr(input.Logger).DebugContext(ctx, "HTTP request completed", "method", input.Method, "url", url, "status", resp.StatusCode, "duration", time.Since(start))
 
 	data, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if resp.StatusCode >= 400 {
-		return nil, &FetchError{Response: resp, Data: string(data)}
+		return nil, resp, &FetchError{Response: resp, Data: string(data), Body: ParseErrorBody(data)}
 	}
 
-	return &FetchXResponse{Data: data, Response: resp}, nil
+	return &FetchXResponse{Data: data, Response: resp}, resp, nil
 }
 
 func GetOperator(session *types.Session) (string, error) {
@@ -119,7 +196,7 @@ func GetOperator(session *types.Session) (string, error) {
 	if len(tokenParts) < 2 {
 		return "", errors.New("invalid access token format")
 	}
-	decodedToken, err := base64.RawStdEncoding.DecodeString(tokenParts[1])
+	decodedToken, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(tokenParts[1], "="))
 	if err != nil {
 		return "unknown", fmt.Errorf("failed to decode token: %v", err)
 	}
@@ -131,59 +208,60 @@ func GetOperator(session *types.Session) (string, error) {
 }
 
 func ReportMetric(report types.MetricInfo) {
-	reportToServer := map[string]interface{}{
-		"sessionId":  report.SessionId,
-		"metricName": report.MetricName,
-		"timestamp":  report.Timestamp.Format(time.RFC3339), // ISO 8601 format
-		"api":        report.Api,
-		"clientId":   report.ClientId,
-		"operator":   report.Operator,
-	}
+	ReportMetricWithContext(context.Background(), report)
+}
+
+// ReportMetricWithContext synchronously posts a metric to REPORT_METRIC_URL,
+// giving up on retries once ctx is done. Services report through
+// GlideSdkSettings.Metrics instead, which does not block.
+func ReportMetricWithContext(ctx context.Context, report types.MetricInfo) {
 	url := os.Getenv("REPORT_METRIC_URL")
 	if url == "" {
 		Logger.Debug("missing process env REPORT_METRIC_URL")
 		return
 	}
-	const maxRetries = 3
-	attempt := 0
-	retryDelay := func(attempt int) time.Duration {
-		return time.Duration(1<<attempt) * time.Second // Exponential backoff: 1s, 2s, 4s
+	if err := sendMetric(ctx, nil, Logger, url, metricRetryPolicy, metricPayload(report)); err != nil {
+		Logger.Error("Failed to report metric", "metric", report.MetricName, "error", err)
 	}
-	for attempt < maxRetries {
-		err := sendMetric(url, reportToServer)
-		if err == nil {
-			return // Successfully sent the metric
-		}
-		Logger.Warn("Error reporting to metric server (attempt %d): %v\n", attempt+1, err)
-		attempt++
-		if attempt < maxRetries {
-			time.Sleep(retryDelay(attempt))
-		}
+}
+
+func metricPayload(report types.MetricInfo) map[string]interface{} {
+	return map[string]interface{}{
+		"sessionId":  report.SessionId,
+		"metricName": report.MetricName,
+		"timestamp":  report.Timestamp.Format(time.RFC3339), // ISO 8601 format
+		"api":        report.Api,
+		"clientId":   report.ClientId,
+		"operator":   report.Operator,
 	}
-	Logger.Error("Failed to report metric after multiple attempts")
 }
 
-func sendMetric(url string, data map[string]interface{}) error {
-	Logger.Debug("Sending metric to: %s", url)
+// metricRetryPolicy keeps the historical 3 attempts with 2s/4s backoff.
+var metricRetryPolicy = &types.RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 2 * time.Second,
+	MaxBackoff:     4 * time.Second,
+}
+
+func sendMetric(ctx context.Context, client *http.Client, logger *slog.Logger, url string, retry *types.RetryPolicy, data map[string]interface{}) error {
+	logger.DebugContext(ctx, "Sending metric", "url", url)
 	payload, err := json.Marshal(data)
 	if err != nil {
 		return fmt.Errorf("failed to marshal report data: %w", err)
 	}
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(payload))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	_, err = FetchXWithContext(ctx, url, FetchXInput{
+		Client: client,
+		Logger: logger,
+		Method: "POST",
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+		Body:       string(payload),
+		Retry:      retry,
+		Idempotent: true,
+	})
 	if err != nil {
 		return fmt.Errorf("request failed: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("non-OK HTTP status: %s", resp.Status)
-	}
 	return nil
 }